@@ -0,0 +1,87 @@
+package browser
+
+import (
+	"net/url"
+	"slices"
+	"strings"
+	"sync"
+
+	"github.com/go-rod/rod"
+	"github.com/go-rod/rod/lib/proto"
+)
+
+// intercepting reports whether requests have to pass through the tab's router, to be
+// blocked or to have their Authorization header added.
+func (b *Browser) intercepting() bool {
+	return len(b.opts.BlockResources) > 0 || len(b.opts.BlockURLs) > 0 || b.opts.Authorization != nil
+}
+
+// interceptRequests intercepts requests made by the tab while rendering pageURL, fails the blocked
+// ones and adds the Authorization header to the others. The returned function lists the urls
+// blocked so far.
+func (b *Browser) interceptRequests(tab *rod.Page, pageURL string) (*rod.HijackRouter, func() []string, error) {
+	var mutex sync.Mutex
+	blocked := []string{}
+	router := tab.HijackRequests()
+	err := router.Add("*", "", func(h *rod.Hijack) {
+		u := h.Request.URL()
+		if u.String() == pageURL || !b.blocked(h.Request.Type(), u) {
+			h.ContinueRequest(&proto.FetchContinueRequest{Headers: b.authorize(u, h.Request.Headers())})
+			return
+		}
+		mutex.Lock()
+		blocked = append(blocked, u.String())
+		mutex.Unlock()
+		h.Response.Fail(proto.NetworkErrorReasonBlockedByClient)
+	})
+	if err != nil {
+		return nil, nil, err
+	}
+	go router.Run()
+	return router, func() []string {
+		mutex.Lock()
+		defer mutex.Unlock()
+		return slices.Clone(blocked)
+	}, nil
+}
+
+// authorize returns the headers of a request to u with the Authorization header for u added,
+// or nil to send them unchanged. The header is worked out for every request, redirect hop and
+// subresource on its own, so credentials for one domain never reach another.
+func (b *Browser) authorize(u *url.URL, headers proto.NetworkHeaders) []*proto.FetchHeaderEntry {
+	if b.opts.Authorization == nil {
+		return nil
+	}
+	auth := b.opts.Authorization(u.String())
+	if auth == "" {
+		return nil
+	}
+	entries := make([]*proto.FetchHeaderEntry, 0, len(headers)+1)
+	for name, value := range headers {
+		if !strings.EqualFold(name, "Authorization") {
+			entries = append(entries, &proto.FetchHeaderEntry{Name: name, Value: value.Str()})
+		}
+	}
+	return append(entries, &proto.FetchHeaderEntry{Name: "Authorization", Value: auth})
+}
+
+// blocked matches a request against the resource type and url rules.
+// URL rules match a domain and its subdomains, or any part of the url.
+func (b *Browser) blocked(resourceType proto.NetworkResourceType, u *url.URL) bool {
+	for _, t := range b.opts.BlockResources {
+		if strings.EqualFold(t, string(resourceType)) {
+			return true
+		}
+	}
+	host := u.Hostname()
+	full := u.String()
+	for _, pattern := range b.opts.BlockURLs {
+		if pattern == "" {
+			continue
+		}
+		if host == pattern || strings.HasSuffix(host, "."+pattern) || strings.Contains(full, pattern) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,49 @@
+package browser
+
+import (
+	"net/url"
+	"strings"
+	"testing"
+
+	"github.com/go-rod/rod/lib/proto"
+	"github.com/stretchr/testify/assert"
+	"github.com/ysmood/gson"
+)
+
+func TestAuthorize(t *testing.T) {
+	b := New(Options{Authorization: func(requestURL string) string {
+		u, _ := url.Parse(requestURL)
+		if u.Hostname() == "example.com" || strings.HasSuffix(u.Hostname(), ".example.com") {
+			return "Bearer secret"
+		}
+		return ""
+	}})
+	headers := proto.NetworkHeaders{
+		"Accept":        gson.New("text/html"),
+		"Authorization": gson.New("Basic stale"),
+	}
+	tests := []struct {
+		url  string
+		want []*proto.FetchHeaderEntry
+	}{
+		{"https://example.com/page", []*proto.FetchHeaderEntry{
+			{Name: "Accept", Value: "text/html"},
+			{Name: "Authorization", Value: "Bearer secret"},
+		}},
+		{"https://static.example.com/app.js", []*proto.FetchHeaderEntry{
+			{Name: "Accept", Value: "text/html"},
+			{Name: "Authorization", Value: "Bearer secret"},
+		}},
+		// a cross-origin subresource of the page is sent as chrome made it
+		{"https://cdn.other.com/app.js", nil},
+		{"https://example.com.evil.net/track.gif", nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.url, func(t *testing.T) {
+			u, err := url.Parse(tt.url)
+			assert.NoError(t, err)
+			assert.Equal(t, tt.want, b.authorize(u, headers))
+		})
+	}
+	assert.Nil(t, New(Options{}).authorize(&url.URL{Scheme: "https", Host: "example.com"}, headers))
+}
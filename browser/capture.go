@@ -0,0 +1,76 @@
+package browser
+
+import (
+	"crypto/sha1"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+
+	"github.com/go-rod/rod"
+	"github.com/go-rod/rod/lib/proto"
+)
+
+var unsafeFileChars = regexp.MustCompile(`[^a-zA-Z0-9._-]+`)
+
+// screenshot saves a full-page screenshot of the page and returns the file path.
+func (b *Browser) screenshot(page *rod.Page, pageURL string) (string, error) {
+	format, ext := screenshotFormat(b.opts.Screenshot)
+	data, err := page.Screenshot(true, &proto.PageCaptureScreenshot{Format: format})
+	if err != nil {
+		return "", fmt.Errorf("failed to capture screenshot of %s: %w", pageURL, err)
+	}
+	return b.save(pageURL, ext, data)
+}
+
+// screenshotFormat returns the capture format and file extension for the Screenshot option.
+func screenshotFormat(option string) (proto.PageCaptureScreenshotFormat, string) {
+	if option == "jpeg" || option == "jpg" {
+		return proto.PageCaptureScreenshotFormatJpeg, "jpg"
+	}
+	return proto.PageCaptureScreenshotFormatPng, "png"
+}
+
+// pdf prints the page to PDF and returns the file path.
+func (b *Browser) pdf(page *rod.Page, pageURL string) (string, error) {
+	stream, err := page.PDF(&proto.PagePrintToPDF{PrintBackground: true})
+	if err != nil {
+		return "", fmt.Errorf("failed to print %s to pdf: %w", pageURL, err)
+	}
+	defer func() {
+		_ = stream.Close()
+	}()
+	data, err := io.ReadAll(stream)
+	if err != nil {
+		return "", fmt.Errorf("failed to read pdf of %s: %w", pageURL, err)
+	}
+	return b.save(pageURL, "pdf", data)
+}
+
+// save writes capture data to the output directory.
+func (b *Browser) save(pageURL, ext string, data []byte) (string, error) {
+	if err := os.MkdirAll(b.opts.OutputDir, 0o755); err != nil {
+		return "", fmt.Errorf("failed to create output directory: %w", err)
+	}
+	path := filepath.Join(b.opts.OutputDir, captureName(pageURL, ext))
+	if err := os.WriteFile(path, data, 0o644); err != nil {
+		return "", fmt.Errorf("failed to write %s: %w", path, err)
+	}
+	return path, nil
+}
+
+// captureName builds a readable, unique file name for a URL.
+func captureName(pageURL, ext string) string {
+	name := pageURL
+	if i := strings.Index(name, "://"); i != -1 {
+		name = name[i+3:]
+	}
+	name = strings.Trim(unsafeFileChars.ReplaceAllString(name, "_"), "_.")
+	if len(name) > 100 {
+		name = name[:100]
+	}
+	sum := sha1.Sum([]byte(pageURL))
+	return fmt.Sprintf("%s_%x.%s", name, sum[:4], ext)
+}
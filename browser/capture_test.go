@@ -0,0 +1,63 @@
+package browser
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/go-rod/rod/lib/proto"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCaptureName(t *testing.T) {
+	name := captureName("https://example.com/blog/post?id=1#top", "png")
+	assert.True(t, strings.HasPrefix(name, "example.com_blog_post_id_1_top_"), name)
+	assert.True(t, strings.HasSuffix(name, ".png"), name)
+
+	// urls that only differ in characters replaced for the file name still get their own file
+	assert.NotEqual(t, captureName("https://example.com/a?b", "png"), captureName("https://example.com/a/b", "png"))
+	assert.Equal(t, captureName("https://example.com/a", "pdf"), captureName("https://example.com/a", "pdf"))
+
+	long := captureName("https://example.com/"+strings.Repeat("x", 300), "jpg")
+	assert.LessOrEqual(t, len(long), 100+len("_12345678.jpg"))
+	assert.NotContains(t, captureName("https://example.com/../../etc/passwd", "png"), "/")
+}
+
+func TestScreenshotFormat(t *testing.T) {
+	tests := []struct {
+		option     string
+		wantFormat proto.PageCaptureScreenshotFormat
+		wantExt    string
+	}{
+		{"png", proto.PageCaptureScreenshotFormatPng, "png"},
+		{"jpeg", proto.PageCaptureScreenshotFormatJpeg, "jpg"},
+		{"jpg", proto.PageCaptureScreenshotFormatJpeg, "jpg"},
+		{"webp", proto.PageCaptureScreenshotFormatPng, "png"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.option, func(t *testing.T) {
+			format, ext := screenshotFormat(tt.option)
+			assert.Equal(t, tt.wantFormat, format)
+			assert.Equal(t, tt.wantExt, ext)
+		})
+	}
+}
+
+func TestSaveCapture(t *testing.T) {
+	dir := filepath.Join(t.TempDir(), "captures")
+	b := New(Options{OutputDir: dir})
+	path, err := b.save("https://example.com/", "png", []byte("image"))
+	assert.NoError(t, err)
+	assert.Equal(t, dir, filepath.Dir(path))
+	data, err := os.ReadFile(path)
+	assert.NoError(t, err)
+	assert.Equal(t, "image", string(data))
+
+	// an output directory that can't be created is reported, not panicked on
+	blocked := filepath.Join(t.TempDir(), "file")
+	assert.NoError(t, os.WriteFile(blocked, nil, 0o644))
+	b = New(Options{OutputDir: filepath.Join(blocked, "captures")})
+	_, err = b.save("https://example.com/", "png", []byte("image"))
+	assert.ErrorContains(t, err, "failed to create output directory")
+}
@@ -0,0 +1,163 @@
+package browser
+
+import (
+	"compress/gzip"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/go-rod/rod/lib/launcher"
+	"github.com/ysmood/fetchup"
+)
+
+// Revision is the chromium revision installed by default.
+const Revision = launcher.RevisionDefault
+
+// ErrNotFound is returned when javascript rendering is requested but no browser is available.
+var ErrNotFound = errors.New(`no chromium browser was found, either:
+	1. install chromium and set CHROME_EXECUTABLE to the chromium executable, or
+	2. run "html-web-crawler install" to download one`)
+
+// InstallOptions control where and how a browser is installed.
+type InstallOptions struct {
+	Dir      string // root directory for installed revisions, defaults to CacheDir
+	Archive  string // install from a local zip or tar.gz instead of downloading
+	Revision int    // chromium revision, defaults to Revision
+}
+
+// CacheDir is the default directory browsers are installed to.
+func CacheDir() string {
+	dir, err := os.UserCacheDir()
+	if err != nil {
+		dir = os.TempDir()
+	}
+	return filepath.Join(dir, "html-web-crawler", "browser")
+}
+
+// Install provisions a chromium build, verifies it runs and returns the executable path.
+func Install(opts InstallOptions) (string, error) {
+	lc := installer(opts)
+	if opts.Archive != "" {
+		if err := unpack(opts.Archive, lc.Dir()); err != nil {
+			return "", fmt.Errorf("failed to install from %s: %w", opts.Archive, err)
+		}
+	} else if lc.Validate() != nil {
+		_ = os.RemoveAll(lc.Dir())
+		if err := lc.Download(); err != nil {
+			return "", fmt.Errorf("failed to download chromium r%d: %w", lc.Revision, err)
+		}
+	}
+	if err := lc.Validate(); err != nil {
+		return "", fmt.Errorf("installed browser %s failed verification: %w", lc.BinPath(), err)
+	}
+	return lc.BinPath(), nil
+}
+
+// FindExecutable returns the browser to launch, checking CHROME_EXECUTABLE,
+// then the revisions installed in CacheDir, then browsers installed on the system.
+func FindExecutable() (string, error) {
+	if chromeExec != "" {
+		return chromeExec, nil
+	}
+	if bin, ok := installed(CacheDir()); ok {
+		return bin, nil
+	}
+	if bin, ok := launcher.LookPath(); ok {
+		return bin, nil
+	}
+	return "", ErrNotFound
+}
+
+// Discovered reports whether FindExecutable picks the browser at path without
+// CHROME_EXECUTABLE being set.
+func Discovered(path string) bool {
+	bin, ok := installed(CacheDir())
+	return ok && bin == path
+}
+
+// installed returns the executable of a revision installed in dir: the pinned
+// Revision when it is there, otherwise the newest one.
+func installed(dir string) (string, bool) {
+	if bin := installer(InstallOptions{Dir: dir}).BinPath(); fileExists(bin) {
+		return bin, true
+	}
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return "", false
+	}
+	newest := ""
+	newestRevision := 0
+	for _, entry := range entries {
+		name, ok := strings.CutPrefix(entry.Name(), "chromium-")
+		if !ok || !entry.IsDir() {
+			continue
+		}
+		revision, err := strconv.Atoi(name)
+		if err != nil || revision <= newestRevision {
+			continue
+		}
+		if bin := installer(InstallOptions{Dir: dir, Revision: revision}).BinPath(); fileExists(bin) {
+			newest, newestRevision = bin, revision
+		}
+	}
+	return newest, newest != ""
+}
+
+// installer configures the rod downloader for the given options.
+func installer(opts InstallOptions) *launcher.Browser {
+	lc := launcher.NewBrowser()
+	lc.RootDir = CacheDir()
+	if opts.Dir != "" {
+		lc.RootDir = opts.Dir
+	}
+	lc.Revision = Revision
+	if opts.Revision > 0 {
+		lc.Revision = opts.Revision
+	}
+	lc.Logger = log.New(os.Stdout, "", log.LstdFlags)
+	return lc
+}
+
+// unpack extracts a zip or tar.gz chromium archive into dir.
+func unpack(archive, dir string) error {
+	isZip := strings.HasSuffix(archive, ".zip")
+	if !isZip && !strings.HasSuffix(archive, ".tar.gz") && !strings.HasSuffix(archive, ".tgz") {
+		return errors.New("unsupported archive type, expected .zip or .tar.gz")
+	}
+	f, err := os.Open(archive)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		_ = f.Close()
+	}()
+	_ = os.RemoveAll(dir)
+	fu := fetchup.New(dir)
+	if isZip {
+		err = fu.UnZip(f)
+	} else {
+		var gz io.ReadCloser
+		gz, err = gzip.NewReader(f)
+		if err != nil {
+			return err
+		}
+		defer func() {
+			_ = gz.Close()
+		}()
+		err = fu.UnTar(gz)
+	}
+	if err != nil {
+		return err
+	}
+	return fetchup.StripFirstDir(dir)
+}
+
+func fileExists(path string) bool {
+	info, err := os.Stat(path)
+	return err == nil && !info.IsDir()
+}
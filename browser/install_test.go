@@ -0,0 +1,64 @@
+package browser
+
+import (
+	"os"
+	"path/filepath"
+	"runtime"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// fakeInstall creates an executable where revision would be installed in dir.
+func fakeInstall(t *testing.T, dir string, revision int) string {
+	t.Helper()
+	bin := installer(InstallOptions{Dir: dir, Revision: revision}).BinPath()
+	assert.NoError(t, os.MkdirAll(filepath.Dir(bin), 0o755))
+	assert.NoError(t, os.WriteFile(bin, nil, 0o755))
+	return bin
+}
+
+func TestInstalled(t *testing.T) {
+	dir := t.TempDir()
+	_, ok := installed(dir)
+	assert.False(t, ok)
+	_, ok = installed(filepath.Join(dir, "missing"))
+	assert.False(t, ok)
+
+	// the newest revision is used when the pinned one isn't installed
+	fakeInstall(t, dir, 1000)
+	newest := fakeInstall(t, dir, Revision+1)
+	assert.NoError(t, os.MkdirAll(filepath.Join(dir, "chromium-99999999"), 0o755)) // an unfinished install
+	assert.NoError(t, os.MkdirAll(filepath.Join(dir, "chromium-latest"), 0o755))
+	bin, ok := installed(dir)
+	assert.True(t, ok)
+	assert.Equal(t, newest, bin)
+
+	pinned := fakeInstall(t, dir, Revision)
+	bin, ok = installed(dir)
+	assert.True(t, ok)
+	assert.Equal(t, pinned, bin)
+}
+
+func TestFindExecutable(t *testing.T) {
+	if runtime.GOOS != "linux" {
+		t.Skip("the user cache dir is only moved with XDG_CACHE_HOME on linux")
+	}
+	t.Setenv("XDG_CACHE_HOME", t.TempDir())
+	saved := chromeExec
+	defer func() { chromeExec = saved }()
+	chromeExec = ""
+
+	// a revision other than the pinned one installed into the default dir is found
+	bin := fakeInstall(t, CacheDir(), 1000)
+	found, err := FindExecutable()
+	assert.NoError(t, err)
+	assert.Equal(t, bin, found)
+	assert.True(t, Discovered(bin))
+	assert.False(t, Discovered(fakeInstall(t, t.TempDir(), Revision)))
+
+	chromeExec = "/opt/chrome/chrome"
+	found, err = FindExecutable()
+	assert.NoError(t, err)
+	assert.Equal(t, "/opt/chrome/chrome", found)
+}
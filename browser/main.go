@@ -1,43 +1,248 @@
 package browser
 
 import (
+	"context"
+	"encoding/base64"
+	"errors"
 	"fmt"
-	"log"
+	"net/http"
 	"os"
+	"strings"
+	"sync"
+	"sync/atomic"
 	"time"
 
 	"github.com/go-rod/rod"
 	"github.com/go-rod/rod/lib/launcher"
-	"github.com/go-rod/rod/lib/utils"
+	"github.com/go-rod/rod/lib/proto"
 )
 
 var (
 	chromeExec = os.Getenv("CHROME_EXECUTABLE")
 )
 
-func GetHtmlContent(pageURL string) (string, error) {
-	b := launcher.NewBrowser()
-	if b.Validate() != nil && chromeExec == "" {
-		log.Fatal(`Attempted to use javascript engine, but no chromium browser was found.
-		You can fix this two ways:
-		1. installing chromium and set CHROME_EXECUTABLE path to chromium executable.
-		2. running the "./html-web-cralwer install" command to automatically install.
-`)
-	}
-	u := launcher.New().Bin(chromeExec).MustLaunch()
-	page := rod.New().ControlURL(u).MustConnect().MustPage(pageURL).Timeout(time.Second)
-	content, err := page.HTML()
+// Options control how pages are rendered and captured.
+type Options struct {
+	Timeout        time.Duration
+	Pages          int // maximum number of tabs open at once
+	ViewportWidth  int
+	ViewportHeight int
+	Screenshot     string // "png" or "jpeg" for full-page screenshots, empty to disable
+	PDF            bool
+	OutputDir      string   // directory screenshots and PDFs are written to
+	RemoteURL      string   // DevTools endpoint of a running chrome, launches locally when empty
+	BlockResources []string // resource types to skip, such as image, media, font or stylesheet
+	BlockURLs      []string // domains or url patterns to skip
+	UserAgents     []string // user agents used in turn, chrome's own when empty
+	Headers        map[string]string
+	Jar            http.CookieJar // cookies sent with pages and updated from them, shared with plain requests
+	// Authorization returns the Authorization header for a url, or "" for none. It is asked
+	// about every request the page makes, subresources on other hosts included
+	Authorization func(requestURL string) string
+	// ProxyPAC is a proxy auto-config script routing the traffic of a launched chrome,
+	// remote browsers keep their own proxy settings
+	ProxyPAC string
+}
+
+// Page is the rendered result of a single URL.
+type Page struct {
+	HTML       string
+	URL        string   // where the page ended up after redirects
+	Screenshot string   // path to the saved screenshot, if any
+	PDF        string   // path to the saved PDF, if any
+	CaptureErr error    // why a screenshot or PDF could not be saved, the HTML is still good
+	Blocked    []string // urls of requests skipped by the blocking rules
+}
+
+// Browser is a chrome instance shared by concurrent renders.
+// Chrome is only launched, or connected to, on the first call to Render.
+type Browser struct {
+	opts       Options
+	mutex      sync.Mutex
+	launcher   *launcher.Launcher
+	rod        *rod.Browser
+	pool       rod.Pool[rod.Page]
+	disconnect context.CancelFunc
+	requests   atomic.Uint64 // renders started, for user agent rotation
+}
+
+// New creates a browser with the given options without launching it.
+func New(opts Options) *Browser {
+	if opts.Pages < 1 {
+		opts.Pages = 1
+	}
+	if opts.Timeout <= 0 {
+		opts.Timeout = 10 * time.Second
+	}
+	return &Browser{
+		opts: opts,
+		pool: rod.NewPagePool(opts.Pages),
+	}
+}
+
+// connect launches chrome, or attaches to the remote endpoint, once and returns the shared connection.
+func (b *Browser) connect() (*rod.Browser, error) {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+	if b.rod != nil {
+		return b.rod, nil
+	}
+	if b.opts.RemoteURL != "" {
+		return b.connectRemote()
+	}
+	bin, err := FindExecutable()
+	if err != nil {
+		return nil, err
+	}
+	l := launcher.New().Bin(bin)
+	if b.opts.ProxyPAC != "" {
+		l.Set("proxy-pac-url", "data:application/x-ns-proxy-autoconfig;base64,"+
+			base64.StdEncoding.EncodeToString([]byte(b.opts.ProxyPAC)))
+	}
+	u, err := l.Launch()
 	if err != nil {
-		fmt.Println(err)
+		return nil, fmt.Errorf("failed to launch browser: %w", err)
 	}
+	r := rod.New().ControlURL(u)
+	if err := r.Connect(); err != nil {
+		l.Kill()
+		return nil, fmt.Errorf("failed to connect to browser: %w", err)
+	}
+	b.launcher = l
+	b.rod = r
+	return r, nil
+}
 
-	return string(content), nil
+// connectRemote attaches to an already running chrome through its DevTools endpoint.
+// Pages are opened in a private browser context so closing them never affects
+// other clients of the shared browser.
+func (b *Browser) connectRemote() (*rod.Browser, error) {
+	u := b.opts.RemoteURL
+	if !strings.HasPrefix(u, "ws://") && !strings.HasPrefix(u, "wss://") {
+		resolved, err := launcher.ResolveURL(u)
+		if err != nil {
+			return nil, fmt.Errorf("failed to resolve devtools endpoint %s: %w", u, err)
+		}
+		u = resolved
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	r := rod.New().Context(ctx).ControlURL(u)
+	if err := r.Connect(); err != nil {
+		cancel()
+		return nil, fmt.Errorf("failed to connect to browser at %s: %w", u, err)
+	}
+	incognito, err := r.Incognito()
+	if err != nil {
+		cancel()
+		return nil, fmt.Errorf("failed to create browser context: %w", err)
+	}
+	b.disconnect = cancel
+	b.rod = incognito
+	return incognito, nil
 }
 
-func Install() error {
-	p, err := launcher.NewBrowser().Get()
-	utils.E(err)
+// Render loads the URL in a browser tab and returns its HTML along with any requested captures.
+func (b *Browser) Render(pageURL string) (*Page, error) {
+	r, err := b.connect()
+	if err != nil {
+		return nil, err
+	}
+	tab, err := b.pool.Get(func() (*rod.Page, error) {
+		return r.Page(proto.TargetCreateTarget{})
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to open browser tab: %w", err)
+	}
+	defer b.pool.Put(tab)
 
-	fmt.Println(p)
-	return nil
+	if b.opts.ViewportWidth > 0 && b.opts.ViewportHeight > 0 {
+		err = tab.SetViewport(&proto.EmulationSetDeviceMetricsOverride{
+			Width:             b.opts.ViewportWidth,
+			Height:            b.opts.ViewportHeight,
+			DeviceScaleFactor: 1,
+		})
+		if err != nil {
+			return nil, fmt.Errorf("failed to set viewport: %w", err)
+		}
+	}
+
+	blocked := func() []string { return nil }
+	if b.intercepting() {
+		var router *rod.HijackRouter
+		router, blocked, err = b.interceptRequests(tab, pageURL)
+		if err != nil {
+			return nil, fmt.Errorf("failed to intercept requests: %w", err)
+		}
+		defer func() {
+			_ = router.Stop()
+		}()
+	}
+
+	removeHeaders, err := b.identify(tab, pageURL)
+	defer removeHeaders()
+	if err != nil {
+		return nil, fmt.Errorf("failed to set request headers: %w", err)
+	}
+
+	page := tab.Timeout(b.opts.Timeout)
+	if err := page.Navigate(pageURL); err != nil {
+		return nil, fmt.Errorf("failed to navigate to %s: %w", pageURL, err)
+	}
+	if err := page.WaitLoad(); err != nil {
+		return nil, fmt.Errorf("failed waiting for %s to load: %w", pageURL, err)
+	}
+	if err := b.keepCookies(page, pageURL); err != nil {
+		return nil, fmt.Errorf("failed to read cookies of %s: %w", pageURL, err)
+	}
+	content, err := page.HTML()
+	if err != nil {
+		return nil, fmt.Errorf("failed to read html of %s: %w", pageURL, err)
+	}
+	info, err := page.Info()
+	if err != nil {
+		return nil, fmt.Errorf("failed to read the url of %s: %w", pageURL, err)
+	}
+	result := &Page{HTML: content, URL: info.URL, Blocked: blocked()}
+	// a failed capture doesn't cost the page, it is reported along with the HTML
+	var screenshotErr, pdfErr error
+	if b.opts.Screenshot != "" {
+		result.Screenshot, screenshotErr = b.screenshot(page, pageURL)
+	}
+	if b.opts.PDF {
+		result.PDF, pdfErr = b.pdf(page, pageURL)
+	}
+	result.CaptureErr = errors.Join(screenshotErr, pdfErr)
+	return result, nil
+}
+
+// Close shuts down chrome if it was launched, or releases the remote connection.
+func (b *Browser) Close() {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+	b.pool.Cleanup(func(p *rod.Page) {
+		_ = p.Close()
+	})
+	if b.rod != nil {
+		_ = b.rod.Close()
+		b.rod = nil
+	}
+	if b.launcher != nil {
+		b.launcher.Cleanup()
+		b.launcher = nil
+	}
+	if b.disconnect != nil {
+		b.disconnect()
+		b.disconnect = nil
+	}
+}
+
+// GetHtmlContent renders a single URL with a temporary browser and returns its HTML.
+func GetHtmlContent(pageURL string) (string, error) {
+	b := New(Options{})
+	defer b.Close()
+	page, err := b.Render(pageURL)
+	if err != nil {
+		return "", err
+	}
+	return page.HTML, nil
 }
@@ -0,0 +1,94 @@
+package browser
+
+import (
+	"net/http"
+	"net/url"
+	"strings"
+
+	"github.com/go-rod/rod"
+	"github.com/go-rod/rod/lib/proto"
+)
+
+// userAgent returns the next user agent of the rotation, or "" to keep chrome's own.
+func (b *Browser) userAgent() string {
+	if len(b.opts.UserAgents) == 0 {
+		return ""
+	}
+	n := b.requests.Add(1) - 1
+	return b.opts.UserAgents[n%uint64(len(b.opts.UserAgents))]
+}
+
+// identify sets the user agent, extra headers and jar cookies on the tab before it loads pageURL.
+// The returned function removes the extra headers again.
+func (b *Browser) identify(tab *rod.Page, pageURL string) (func(), error) {
+	cleanup := func() {}
+	if agent := b.userAgent(); agent != "" {
+		if err := tab.SetUserAgent(&proto.NetworkSetUserAgentOverride{UserAgent: agent}); err != nil {
+			return cleanup, err
+		}
+	}
+	dict := make([]string, 0, 2*len(b.opts.Headers))
+	for name, value := range b.opts.Headers {
+		dict = append(dict, name, value)
+	}
+	if len(dict) > 0 {
+		remove, err := tab.SetExtraHeaders(dict)
+		if err != nil {
+			return cleanup, err
+		}
+		cleanup = remove
+	}
+	if b.opts.Jar == nil {
+		return cleanup, nil
+	}
+	u, err := url.Parse(pageURL)
+	if err != nil {
+		return cleanup, err
+	}
+	params := []*proto.NetworkCookieParam{}
+	for _, cookie := range b.opts.Jar.Cookies(u) {
+		params = append(params, &proto.NetworkCookieParam{Name: cookie.Name, Value: cookie.Value, URL: pageURL})
+	}
+	if len(params) > 0 {
+		if err := tab.SetCookies(params); err != nil {
+			return cleanup, err
+		}
+	}
+	return cleanup, nil
+}
+
+// keepCookies copies the cookies chrome holds for pageURL back into the jar,
+// so sessions started by scripts carry over to plain HTTP requests.
+func (b *Browser) keepCookies(tab *rod.Page, pageURL string) error {
+	if b.opts.Jar == nil {
+		return nil
+	}
+	u, err := url.Parse(pageURL)
+	if err != nil {
+		return err
+	}
+	cookies, err := tab.Cookies([]string{pageURL})
+	if err != nil {
+		return err
+	}
+	kept := make([]*http.Cookie, 0, len(cookies))
+	for _, c := range cookies {
+		cookie := &http.Cookie{
+			Name:     c.Name,
+			Value:    c.Value,
+			Path:     c.Path,
+			Secure:   c.Secure,
+			HttpOnly: c.HTTPOnly,
+		}
+		// chrome prefixes domain cookies with a dot, host-only cookies have none
+		if strings.HasPrefix(c.Domain, ".") {
+			cookie.Domain = c.Domain
+		}
+		if !c.Session {
+			cookie.Expires = c.Expires.Time()
+		}
+		kept = append(kept, cookie)
+	}
+	b.opts.Jar.SetCookies(u, kept)
+	return nil
+}
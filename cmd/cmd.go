@@ -1,12 +1,21 @@
 package cmd
 
 import (
+	"encoding/json"
 	"fmt"
 	"log"
+	"maps"
 	"os"
+	"os/signal"
+	"path/filepath"
+	"slices"
+	"strconv"
 	"strings"
+	"syscall"
+	"time"
 
 	"github.com/alecthomas/kong"
+	"github.com/gtsteffaniak/html-web-crawler/browser"
 	"github.com/gtsteffaniak/html-web-crawler/crawler"
 	"github.com/gtsteffaniak/html-web-crawler/version"
 )
@@ -17,6 +26,9 @@ type CLI struct {
 	Crawl   CrawlCmd   `cmd:"" help:"Gather URLs that match search criteria, crawling recursively. Returns full HTML content."`
 	Collect CollectCmd `cmd:"" help:"Intensive collection of specific items (images, search terms, etc). Does not return full HTML."`
 	Install InstallCmd `cmd:"" help:"Install Chrome browser for JavaScript-enabled scraping."`
+	Diff    DiffCmd    `cmd:"" help:"Compare two crawls saved with --output or --state-dir."`
+	Sitemap SitemapCmd `cmd:"" help:"Crawl a site and write a sitemap.xml of the pages found."`
+	Check   CheckCmd   `cmd:"" help:"Crawl a site and report broken internal and external links, exiting non-zero when any are found."`
 }
 
 // GlobalFlags are flags shared across all commands
@@ -29,9 +41,17 @@ type GlobalFlags struct {
 
 // CrawlSettings control the crawling behavior
 type CrawlSettings struct {
-	MaxDepth int `name:"max-depth" help:"Maximum depth for pages to crawl. 1 = only links from given URLs." default:"2"`
-	MaxLinks int `name:"max-links" help:"Limit crawling to a number of pages (0 = unlimited)." default:"0"`
-	JsDepth  int `name:"js-depth" help:"Depth to use JavaScript rendering (requires Chrome)." default:"0"`
+	MaxDepth         int       `name:"max-depth" help:"Maximum depth for pages to crawl. 1 = only links from given URLs." default:"2"`
+	MaxLinks         int       `name:"max-links" help:"Limit crawling to a number of pages (0 = unlimited)." default:"0"`
+	Order            string    `name:"order" help:"Crawl order: bfs (shallowest first), dfs (deepest first) or priority (links matching patterns and search terms first)." enum:"bfs,dfs,priority" default:"bfs"`
+	JsDepth          int       `name:"js-depth" help:"Depth to use JavaScript rendering (requires Chrome)." default:"0"`
+	JsAuto           bool      `name:"js-auto" help:"Fetch pages past --js-depth over HTTP and render with JavaScript only when they look client-rendered, remembered per host."`
+	BrowserWSURL     string    `name:"browser-ws-url" help:"DevTools WebSocket (or http host:port) endpoint of a running Chrome to use for JavaScript rendering." placeholder:"ws://chrome:9222/devtools/browser/<id>"`
+	Sitemaps         bool      `name:"sitemaps" help:"Also crawl the pages listed in each site's sitemaps (from robots.txt or /sitemap.xml)."`
+	SitemapSince     time.Time `name:"sitemap-since" help:"Skip sitemap pages last modified before this date." format:"2006-01-02" placeholder:"YYYY-MM-DD"`
+	Feeds            bool      `name:"feeds" help:"Crawl the entries of RSS/Atom feeds given as URLs or advertised by them as depth 1 pages."`
+	LinkSources      []string  `name:"link-sources" help:"Also follow links from meta refresh, canonical (to skip duplicate pages), pagination (link rel next/prev), area, iframe and frame." enum:"refresh,canonical,pagination,area,iframe,frame" placeholder:"refresh,pagination"`
+	RobotsDirectives bool      `name:"robots-directives" help:"Honor noindex and nofollow in robots meta tags and X-Robots-Tag headers, and skip rel=nofollow, ugc and sponsored links."`
 }
 
 // Selectors control which links to follow and content to collect
@@ -46,6 +66,24 @@ type Selectors struct {
 	ExcludeURLs    []string `name:"exclude-urls" help:"URLs to ignore." placeholder:"url1,url2"`
 }
 
+// NormalizeOptions control how URLs are canonicalized before deduplication
+type NormalizeOptions struct {
+	StripParams        []string `name:"strip-params" help:"Extra query parameters to remove from URLs ('prefix*' supported)." placeholder:"ref,session*"`
+	KeepTrackingParams bool     `name:"keep-tracking-params" help:"Keep utm_*, gclid, fbclid and other tracking parameters in URLs."`
+	TrailingSlash      string   `name:"trailing-slash" help:"Trailing slash policy for URL paths (keep, add, remove)." enum:"keep,add,remove" default:"remove"`
+}
+
+// normalizer builds the URL normalizer from the flags
+func (n *NormalizeOptions) normalizer() crawler.Normalizer {
+	normalizer := crawler.NewNormalizer()
+	if n.KeepTrackingParams {
+		normalizer.TrackingParams = []string{}
+	}
+	normalizer.TrackingParams = append(normalizer.TrackingParams, n.StripParams...)
+	normalizer.TrailingSlash = n.TrailingSlash
+	return normalizer
+}
+
 // SearchOptions control content searching
 type SearchOptions struct {
 	SearchAny []string `name:"search-any" help:"Search for any pattern (OR operator)." placeholder:"term1,term2"`
@@ -57,12 +95,101 @@ type CollectionOptions struct {
 	FileTypes []string `name:"filetypes" help:"File types to collect (pdf, docx, doc, images, video, audio, etc)." placeholder:"images,pdf"`
 }
 
+// CaptureOptions control screenshots and PDFs of JavaScript rendered pages
+type CaptureOptions struct {
+	Screenshot     string `name:"screenshot" help:"Save full-page screenshots of JavaScript rendered pages (png or jpeg)." enum:",png,jpeg" default:""`
+	PDF            bool   `name:"pdf" help:"Save a PDF of each JavaScript rendered page."`
+	OutputDir      string `name:"output-dir" help:"Directory to write screenshots and PDFs to." default:"captures"`
+	ViewportWidth  int    `name:"viewport-width" help:"Browser viewport width in pixels." default:"1920"`
+	ViewportHeight int    `name:"viewport-height" help:"Browser viewport height in pixels." default:"1080"`
+}
+
+// BlockingOptions control which requests are skipped when rendering JavaScript
+type BlockingOptions struct {
+	BlockResources []string `name:"block-resources" help:"Resource types to skip when rendering JavaScript (image, media, font, stylesheet, script)." placeholder:"image,font"`
+	BlockURLs      []string `name:"block-urls" help:"Domains or URL patterns to skip when rendering JavaScript." placeholder:"doubleclick.net,/analytics.js"`
+	BlockList      string   `name:"block-list" help:"File of domains or URL patterns to skip, one per line (hosts file format supported)." type:"existingfile"`
+}
+
+// ContentOptions control which responses are parsed
+type ContentOptions struct {
+	MaxBodySize  int64    `name:"max-body-size" help:"Skip pages larger than this many bytes without reading the rest (0 = unlimited)." default:"10485760"`
+	ContentTypes []string `name:"content-types" help:"Content types to parse for links, other pages are skipped without downloading them." default:"text/html,application/xhtml+xml"`
+	ProbeHead    bool     `name:"probe-head" help:"Send HEAD first so skipped pages are never downloaded or rendered."`
+}
+
+// RequestOptions control the identity requests are sent with
+type RequestOptions struct {
+	UserAgent    []string `name:"user-agent" help:"User-Agent to send, repeat to rotate between several." sep:"none"`
+	Header       []string `name:"header" short:"H" help:"Extra request header, repeatable." placeholder:"NAME: VALUE" sep:"none"`
+	Cookies      string   `name:"cookies" help:"Netscape cookies.txt file to load cookies from and save them back to after the crawl." type:"path"`
+	MaxRedirects int      `name:"max-redirects" help:"Redirects to follow for a request before giving up." default:"10"`
+}
+
+// AuthOptions control how the crawler logs in to the sites it crawls
+type AuthOptions struct {
+	Auth         []string `name:"auth" help:"HTTP basic auth for a domain and its subdomains, repeatable." placeholder:"DOMAIN=USER:PASSWORD" sep:"none"`
+	Bearer       []string `name:"bearer" help:"Bearer token for a domain and its subdomains, repeatable." placeholder:"DOMAIN=TOKEN" sep:"none"`
+	LoginURL     string   `name:"login-url" help:"Page with a login form to submit before the crawl, its session cookies are used by every request."`
+	LoginField   []string `name:"login-field" help:"Login form field to fill in, repeatable (hidden fields such as CSRF tokens are kept)." placeholder:"NAME=VALUE" sep:"none"`
+	LoginSuccess string   `name:"login-success" help:"Text the page shown after logging in must contain, the crawl stops when it is missing."`
+}
+
+// ProxyOptions control the proxies requests are sent through
+type ProxyOptions struct {
+	Proxy     []string `name:"proxy" help:"HTTP(S) or SOCKS5 proxy URL, repeat to rotate between several (unreachable proxies are skipped)." placeholder:"socks5://host:1080"`
+	ProxyRule []string `name:"proxy-rule" help:"Send a domain and its subdomains through a specific proxy, or direct, repeatable." placeholder:"DOMAIN=PROXY|direct" sep:"none"`
+}
+
+// CacheOptions control the on-disk response cache
+type CacheOptions struct {
+	CacheDir string        `name:"cache-dir" help:"Directory to cache responses in between crawls, reused while their Cache-Control or Expires headers allow." type:"path"`
+	CacheTTL time.Duration `name:"cache-ttl" help:"Keep every response in --cache-dir and reuse it for this long, whatever its headers say (for developing selectors)." placeholder:"24h"`
+}
+
+// ReplayOptions record the requests of a crawl to a cassette and replay them offline
+type ReplayOptions struct {
+	Record string `name:"record" help:"Write every request and response to a cassette file." type:"path"`
+	Replay string `name:"replay" help:"Answer requests from a cassette file instead of the network (with --record on the same file, missing requests are fetched and added)." type:"path"`
+}
+
+// StateOptions control checkpointing a crawl so it can be resumed
+type StateOptions struct {
+	StateDir    string `name:"state-dir" help:"Directory to checkpoint the frontier and finished pages to, Ctrl-C pauses the crawl." type:"path"`
+	Resume      bool   `name:"resume" help:"Resume the crawl saved in --state-dir instead of starting over."`
+	Validators  string `name:"validators" help:"File to keep ETag, Last-Modified and content hashes in between crawls, to report pages as new, changed, unchanged or gone (404/410, or no longer linked when the crawl runs to the end)." type:"path"`
+	OnlyChanged bool   `name:"only-changed" help:"Only download and output new and changed pages (needs --validators)."`
+}
+
+// OutputOptions control where crawl results are written
+type OutputOptions struct {
+	Output string `name:"output" help:"Write the crawled pages with their content and results as JSON lines (compare runs with diff)." type:"path"`
+}
+
+// GraphOptions control the exported link graph
+type GraphOptions struct {
+	Graph       string `name:"graph" help:"Write the link graph of the crawl with in-degree, orphan pages and PageRank to a file." type:"path"`
+	GraphFormat string `name:"graph-format" help:"Link graph format (dot, graphml, json), defaults to the --graph file extension." enum:",dot,graphml,json" default:""`
+}
+
 // CrawlCmd crawls URLs and returns full HTML content
 type CrawlCmd struct {
 	GlobalFlags
 	CrawlSettings
 	Selectors
+	NormalizeOptions
 	SearchOptions
+	CaptureOptions
+	BlockingOptions
+	ContentOptions
+	RequestOptions
+	AuthOptions
+	ProxyOptions
+	CacheOptions
+	ReplayOptions
+	StateOptions
+	OutputOptions
+	GraphOptions
 }
 
 // CollectCmd collects specific items from URLs
@@ -70,12 +197,74 @@ type CollectCmd struct {
 	GlobalFlags
 	CrawlSettings
 	Selectors
+	NormalizeOptions
 	SearchOptions
 	CollectionOptions
+	CaptureOptions
+	BlockingOptions
+	ContentOptions
+	RequestOptions
+	AuthOptions
+	ProxyOptions
+	CacheOptions
+	ReplayOptions
+	StateOptions
+	GraphOptions
 }
 
 // InstallCmd installs Chrome for JavaScript rendering
-type InstallCmd struct{}
+type InstallCmd struct {
+	Dir      string `name:"dir" help:"Directory to install the browser into (defaults to the user cache dir, the only one searched for installed browsers)." type:"path"`
+	Archive  string `name:"archive" help:"Install from a local chromium zip or tar.gz archive instead of downloading." type:"existingfile"`
+	Revision int    `name:"revision" help:"Chromium revision to install." default:"${chromium_revision}"`
+}
+
+// DiffCmd compares two crawls of the same site
+type DiffCmd struct {
+	Old  string `arg:"" help:"Older crawl: a --output file or --state-dir directory." type:"existingpath"`
+	New  string `arg:"" help:"Newer crawl: a --output file or --state-dir directory." type:"existingpath"`
+	JSON bool   `name:"json" help:"Print the differences as JSON."`
+}
+
+// SitemapCmd crawls a site and writes its sitemap
+type SitemapCmd struct {
+	GlobalFlags
+	CrawlSettings
+	Selectors
+	NormalizeOptions
+	BlockingOptions
+	ContentOptions
+	RequestOptions
+	AuthOptions
+	ProxyOptions
+	CacheOptions
+	ReplayOptions
+	SitemapOptions
+}
+
+// SitemapOptions control the generated sitemap
+type SitemapOptions struct {
+	Out        string `name:"out" help:"Directory to write sitemap.xml to (and sitemap-N.xml past 50,000 URLs)." default:"." type:"path"`
+	BaseURL    string `name:"base-url" help:"URL the sitemap files are served from, for the sitemap index (defaults to the site root)." placeholder:"https://example.com"`
+	ChangeFreq bool   `name:"changefreq" help:"Add changefreq derived from page depth (daily, weekly, monthly)."`
+	Priority   bool   `name:"priority" help:"Add priority derived from page depth (1.0 for the start pages, 0.2 less per level)."`
+}
+
+// CheckCmd crawls a site for broken links
+type CheckCmd struct {
+	GlobalFlags
+	CrawlSettings
+	Selectors
+	NormalizeOptions
+	BlockingOptions
+	ContentOptions
+	RequestOptions
+	AuthOptions
+	ProxyOptions
+	CacheOptions
+	ReplayOptions
+	JSON bool `name:"json" help:"Print the broken links as JSON."`
+}
 
 // Run executes the crawl command
 func (c *CrawlCmd) Run(ctx *kong.Context) error {
@@ -83,16 +272,33 @@ func (c *CrawlCmd) Run(ctx *kong.Context) error {
 		log.Printf("Starting crawl with %d thread(s)...", c.Threads)
 	}
 
-	crawler := c.buildCrawler()
+	crawler, err := c.buildCrawler()
+	if err != nil {
+		return err
+	}
 
+	stop := c.stopOnInterrupt(crawler, c.Silent)
+	defer stop()
 	urls := c.expandURLs()
 	result, err := crawler.Crawl(urls...)
 	if err != nil {
 		return fmt.Errorf("crawl failed: %w", err)
 	}
 
+	if err := c.writePages(crawler.Pages()); err != nil {
+		return err
+	}
+	if err := c.writeGraph(crawler.Graph(), c.Silent); err != nil {
+		return err
+	}
+
 	if !c.Silent {
 		log.Printf("Crawled %d pages", len(result))
+		c.logCaptures(crawler.Results())
+		c.logSkipped(crawler.Results())
+		c.logDirectives(crawler.Stats())
+		c.logCache(crawler.Stats())
+		c.logChanges(crawler.Results())
 	}
 
 	ctx.Bind(result)
@@ -105,82 +311,620 @@ func (col *CollectCmd) Run(ctx *kong.Context) error {
 		log.Printf("Starting collection with %d thread(s)...", col.Threads)
 	}
 
-	crawler := col.buildCrawler()
+	crawler, err := col.buildCrawler()
+	if err != nil {
+		return err
+	}
 
+	stop := col.stopOnInterrupt(crawler, col.Silent)
+	defer stop()
 	urls := col.expandURLs()
 	result, err := crawler.Collect(urls...)
 	if err != nil {
 		return fmt.Errorf("collection failed: %w", err)
 	}
 
+	if err := col.writeGraph(crawler.Graph(), col.Silent); err != nil {
+		return err
+	}
+
 	if !col.Silent {
 		log.Printf("Collected %d items", len(result))
+		col.logCaptures(crawler.Results())
+		col.logSkipped(crawler.Results())
+		col.logDirectives(crawler.Stats())
+		col.logCache(crawler.Stats())
+		col.logChanges(crawler.Results())
 	}
 
 	ctx.Bind(result)
 	return nil
 }
 
+// Run executes the diff command
+func (d *DiffCmd) Run(ctx *kong.Context) error {
+	oldPages, err := crawler.LoadPages(d.Old)
+	if err != nil {
+		return err
+	}
+	newPages, err := crawler.LoadPages(d.New)
+	if err != nil {
+		return err
+	}
+	diff := crawler.Diff(oldPages, newPages)
+	if d.JSON {
+		enc := json.NewEncoder(os.Stdout)
+		enc.SetIndent("", "  ")
+		return enc.Encode(diff)
+	}
+	printDiff(diff)
+	return nil
+}
+
+// printDiff writes a readable report of the differences between two crawls
+func printDiff(diff crawler.CrawlDiff) {
+	fmt.Printf("%d added, %d removed, %d changed, %d titles changed, %d newly broken\n",
+		len(diff.Added), len(diff.Removed), len(diff.Changed), len(diff.Titles), len(diff.Broken))
+	for _, url := range diff.Added {
+		fmt.Println("added:", url)
+	}
+	for _, url := range diff.Removed {
+		fmt.Println("removed:", url)
+	}
+	for _, t := range diff.Titles {
+		fmt.Printf("title: %s\n  %q -> %q\n", t.URL, t.Old, t.New)
+	}
+	for _, b := range diff.Broken {
+		fmt.Printf("broken: %s (%s)\n", b.URL, b.Error)
+		for _, source := range b.Sources {
+			fmt.Println("  linked from", source)
+		}
+	}
+	for _, c := range diff.Changed {
+		fmt.Println("changed:", c.URL)
+		for _, line := range strings.Split(strings.TrimSuffix(c.Diff, "\n"), "\n") {
+			fmt.Println("  " + line)
+		}
+	}
+}
+
+// Run executes the sitemap command
+func (sm *SitemapCmd) Run(ctx *kong.Context) error {
+	if !sm.Silent {
+		log.Printf("Crawling for sitemap with %d thread(s)...", sm.Threads)
+	}
+	crawler, err := sm.buildCrawler()
+	if err != nil {
+		return err
+	}
+	if _, err := crawler.Crawl(sm.expandURLs()...); err != nil {
+		return fmt.Errorf("crawl failed: %w", err)
+	}
+	files, err := sm.writeSitemaps(crawler.Results())
+	if err != nil {
+		return err
+	}
+	if !sm.Silent {
+		for _, file := range files {
+			log.Printf("Wrote %s", file)
+		}
+	}
+	return nil
+}
+
+// Run executes the check command
+func (ch *CheckCmd) Run(ctx *kong.Context) error {
+	if !ch.Silent {
+		log.Printf("Checking links with %d thread(s)...", ch.Threads)
+	}
+	crawler, err := ch.buildCrawler()
+	if err != nil {
+		return err
+	}
+	broken, err := crawler.Check(ch.expandURLs()...)
+	if err != nil {
+		return fmt.Errorf("check failed: %w", err)
+	}
+	if ch.JSON {
+		enc := json.NewEncoder(os.Stdout)
+		enc.SetIndent("", "  ")
+		if err := enc.Encode(broken); err != nil {
+			return err
+		}
+	} else if !ch.Silent {
+		for _, link := range broken {
+			if link.StatusCode != 0 {
+				fmt.Printf("%d %s\n", link.StatusCode, link.URL)
+			} else {
+				fmt.Printf("ERR %s (%s)\n", link.URL, link.Error)
+			}
+			for _, source := range link.Sources {
+				fmt.Printf("  linked from %s %q\n", source.Page, source.Text)
+			}
+		}
+	}
+	if len(broken) > 0 {
+		return fmt.Errorf("%d broken links found", len(broken))
+	}
+	if !ch.Silent {
+		log.Printf("No broken links found")
+	}
+	return nil
+}
+
 // Run executes the install command
 func (i *InstallCmd) Run(ctx *kong.Context) error {
-	fmt.Println("Chrome installation:")
-	fmt.Println("  Note: Consider installing via your native package manager,")
-	fmt.Println("        then set 'CHROME_EXECUTABLE' in the environment")
-	fmt.Println()
-	fmt.Println("Example installations:")
-	fmt.Println("  macOS:   brew install --cask google-chrome")
-	fmt.Println("  Ubuntu:  sudo apt install google-chrome-stable")
-	fmt.Println("  Fedora:  sudo dnf install google-chrome-stable")
+	dir := i.Dir
+	if dir == "" {
+		dir = browser.CacheDir()
+	}
+	if i.Archive != "" {
+		fmt.Printf("Installing chromium r%d from %s into %s\n", i.Revision, i.Archive, dir)
+	} else {
+		fmt.Printf("Installing chromium r%d into %s\n", i.Revision, dir)
+	}
+	path, err := browser.Install(browser.InstallOptions{
+		Dir:      i.Dir,
+		Archive:  i.Archive,
+		Revision: i.Revision,
+	})
+	if err != nil {
+		return fmt.Errorf("install failed: %w", err)
+	}
+	fmt.Println("Browser installed:", path)
+	if !browser.Discovered(path) {
+		fmt.Println("  Set CHROME_EXECUTABLE to this path to use it for JavaScript rendering.")
+	}
 	return nil
 }
 
 // buildCrawler creates a crawler instance from command flags
-func (c *CrawlCmd) buildCrawler() *crawler.Crawler {
-	cr := crawler.NewCrawler()
-	cr.Threads = c.Threads
-	cr.Timeout = c.Timeout
-	cr.MaxDepth = c.MaxDepth
-	cr.MaxLinks = c.MaxLinks
-	cr.JsDepth = c.JsDepth
-	cr.Silent = c.Silent
-	cr.SearchAny = c.SearchAny
-	cr.SearchAll = c.SearchAll
-
-	cr.Selectors.Ids = c.IdSelectors
-	cr.Selectors.Classes = c.ClassSelectors
-	cr.Selectors.Domains = c.Domains
-	cr.Selectors.ExcludeDomains = c.ExcludeDomains
-	cr.Selectors.LinkTextPatterns = c.LinkText
-	cr.Selectors.UrlPatterns = c.URLPatterns
-	cr.Selectors.ContentPatterns = c.Content
-	cr.Selectors.ExcludedUrls = c.ExcludeURLs
-
-	return cr
+func (c *CrawlCmd) buildCrawler() (*crawler.Crawler, error) {
+	return newCrawler(&c.GlobalFlags, &c.CrawlSettings, &c.Selectors, &c.NormalizeOptions, &c.SearchOptions,
+		&c.CaptureOptions, &c.BlockingOptions, &c.ContentOptions, &c.RequestOptions, &c.AuthOptions,
+		&c.ProxyOptions, &c.CacheOptions, &c.ReplayOptions, &c.StateOptions, &c.GraphOptions)
 }
 
 // buildCrawler creates a crawler instance from command flags
-func (col *CollectCmd) buildCrawler() *crawler.Crawler {
+func (col *CollectCmd) buildCrawler() (*crawler.Crawler, error) {
+	return newCrawler(&col.GlobalFlags, &col.CrawlSettings, &col.Selectors, &col.NormalizeOptions, &col.SearchOptions,
+		&col.CollectionOptions, &col.CaptureOptions, &col.BlockingOptions, &col.ContentOptions, &col.RequestOptions,
+		&col.AuthOptions, &col.ProxyOptions, &col.CacheOptions, &col.ReplayOptions, &col.StateOptions, &col.GraphOptions)
+}
+
+// crawlerOptions is a group of flags shared by the crawling commands
+type crawlerOptions interface {
+	apply(cr *crawler.Crawler) error
+}
+
+// newCrawler creates a crawler with the flag groups a command has applied to it
+func newCrawler(options ...crawlerOptions) (*crawler.Crawler, error) {
 	cr := crawler.NewCrawler()
-	cr.Threads = col.Threads
-	cr.Timeout = col.Timeout
-	cr.MaxDepth = col.MaxDepth
-	cr.MaxLinks = col.MaxLinks
-	cr.JsDepth = col.JsDepth
-	cr.Silent = col.Silent
-	cr.SearchAny = col.SearchAny
-	cr.SearchAll = col.SearchAll
-
-	cr.Selectors.Ids = col.IdSelectors
-	cr.Selectors.Classes = col.ClassSelectors
-	cr.Selectors.Domains = col.Domains
-	cr.Selectors.ExcludeDomains = col.ExcludeDomains
-	cr.Selectors.LinkTextPatterns = col.LinkText
-	cr.Selectors.UrlPatterns = col.URLPatterns
-	cr.Selectors.ContentPatterns = col.Content
-	cr.Selectors.ExcludedUrls = col.ExcludeURLs
-	cr.Selectors.Collections = col.FileTypes
-
-	return cr
+	for _, o := range options {
+		if err := o.apply(cr); err != nil {
+			return nil, err
+		}
+	}
+	return cr, nil
+}
+
+// apply sets the global flags on the crawler
+func (g *GlobalFlags) apply(cr *crawler.Crawler) error {
+	cr.Threads = g.Threads
+	cr.Timeout = g.Timeout
+	cr.Silent = g.Silent
+	return nil
+}
+
+// apply sets the crawl settings on the crawler
+func (s *CrawlSettings) apply(cr *crawler.Crawler) error {
+	cr.MaxDepth = s.MaxDepth
+	cr.MaxLinks = s.MaxLinks
+	cr.Order = s.Order
+	cr.JsDepth = s.JsDepth
+	cr.JsAuto = s.JsAuto
+	cr.BrowserWSURL = s.BrowserWSURL
+	cr.Sitemaps = s.Sitemaps
+	cr.SitemapSince = s.SitemapSince
+	cr.Feeds = s.Feeds
+	cr.LinkSources = s.LinkSources
+	cr.RobotsDirectives = s.RobotsDirectives
+	return nil
+}
+
+// apply sets the link and content selectors on the crawler
+func (s *Selectors) apply(cr *crawler.Crawler) error {
+	cr.Selectors.Ids = s.IdSelectors
+	cr.Selectors.Classes = s.ClassSelectors
+	cr.Selectors.Domains = s.Domains
+	cr.Selectors.ExcludeDomains = s.ExcludeDomains
+	cr.Selectors.LinkTextPatterns = s.LinkText
+	cr.Selectors.UrlPatterns = s.URLPatterns
+	cr.Selectors.ContentPatterns = s.Content
+	cr.Selectors.ExcludedUrls = s.ExcludeURLs
+	return nil
+}
+
+// apply sets the URL normalizer on the crawler
+func (n *NormalizeOptions) apply(cr *crawler.Crawler) error {
+	cr.Normalizer = n.normalizer()
+	return nil
+}
+
+// apply sets the search terms on the crawler
+func (s *SearchOptions) apply(cr *crawler.Crawler) error {
+	cr.SearchAny = s.SearchAny
+	cr.SearchAll = s.SearchAll
+	return nil
+}
+
+// apply sets the file types to collect on the crawler
+func (o *CollectionOptions) apply(cr *crawler.Crawler) error {
+	cr.Selectors.Collections = o.FileTypes
+	return nil
+}
+
+// apply sets the screenshot and PDF options on the crawler
+func (o *CaptureOptions) apply(cr *crawler.Crawler) error {
+	cr.Captures = crawler.Captures{
+		Screenshot:     o.Screenshot,
+		PDF:            o.PDF,
+		OutputDir:      o.OutputDir,
+		ViewportWidth:  o.ViewportWidth,
+		ViewportHeight: o.ViewportHeight,
+	}
+	return nil
+}
+
+// apply sets the request blocking rules on the crawler
+func (b *BlockingOptions) apply(cr *crawler.Crawler) error {
+	blockURLs, err := b.blockURLs()
+	if err != nil {
+		return err
+	}
+	cr.BlockResources = b.BlockResources
+	cr.BlockURLs = blockURLs
+	return nil
+}
+
+// apply sets the response limits on the crawler
+func (o *ContentOptions) apply(cr *crawler.Crawler) error {
+	cr.MaxBodySize = o.MaxBodySize
+	cr.ContentTypes = o.ContentTypes
+	cr.ProbeHead = o.ProbeHead
+	return nil
+}
+
+// apply sets the request identity on the crawler
+func (r *RequestOptions) apply(cr *crawler.Crawler) error {
+	headers, err := r.headers()
+	if err != nil {
+		return err
+	}
+	cr.UserAgents = r.UserAgent
+	cr.Headers = headers
+	cr.CookieFile = r.Cookies
+	cr.MaxRedirects = r.MaxRedirects
+	return nil
+}
+
+// apply sets the credentials and form login on the crawler
+func (a *AuthOptions) apply(cr *crawler.Crawler) error {
+	auth, login, err := a.credentials()
+	if err != nil {
+		return err
+	}
+	cr.Auth = auth
+	cr.Login = login
+	return nil
+}
+
+// apply sets the proxies on the crawler
+func (p *ProxyOptions) apply(cr *crawler.Crawler) error {
+	proxyRules, err := p.proxyRules()
+	if err != nil {
+		return err
+	}
+	cr.Proxies = p.Proxy
+	cr.ProxyRules = proxyRules
+	return nil
+}
+
+// apply sets the response cache on the crawler
+func (o *CacheOptions) apply(cr *crawler.Crawler) error {
+	cr.CacheDir = o.CacheDir
+	cr.CacheTTL = o.CacheTTL
+	return nil
+}
+
+// apply sets the cassettes on the crawler
+func (o *ReplayOptions) apply(cr *crawler.Crawler) error {
+	cr.Record = o.Record
+	cr.Replay = o.Replay
+	return nil
+}
+
+// apply sets the checkpoint and validators files on the crawler
+func (s *StateOptions) apply(cr *crawler.Crawler) error {
+	cr.StateDir = s.StateDir
+	cr.Resume = s.Resume
+	cr.ValidatorsFile = s.Validators
+	cr.OnlyChanged = s.OnlyChanged
+	return nil
+}
+
+// apply has the crawler record the link graph when --graph is set
+func (o *GraphOptions) apply(cr *crawler.Crawler) error {
+	cr.RecordGraph = o.Graph != ""
+	return nil
+}
+
+// writePages saves the crawled pages to --output
+func (o *OutputOptions) writePages(pages []crawler.Page) error {
+	if o.Output == "" {
+		return nil
+	}
+	f, err := os.Create(o.Output)
+	if err != nil {
+		return fmt.Errorf("failed to write output: %w", err)
+	}
+	if err := crawler.WritePages(f, pages); err != nil {
+		_ = f.Close()
+		return err
+	}
+	return f.Close()
+}
+
+// buildCrawler creates a crawler instance from command flags
+func (sm *SitemapCmd) buildCrawler() (*crawler.Crawler, error) {
+	return newCrawler(&sm.GlobalFlags, &sm.CrawlSettings, &sm.Selectors, &sm.NormalizeOptions, &sm.BlockingOptions,
+		&sm.ContentOptions, &sm.RequestOptions, &sm.AuthOptions, &sm.ProxyOptions, &sm.CacheOptions, &sm.ReplayOptions)
+}
+
+// buildCrawler creates a crawler instance from command flags
+func (ch *CheckCmd) buildCrawler() (*crawler.Crawler, error) {
+	return newCrawler(&ch.GlobalFlags, &ch.CrawlSettings, &ch.Selectors, &ch.NormalizeOptions, &ch.BlockingOptions,
+		&ch.ContentOptions, &ch.RequestOptions, &ch.AuthOptions, &ch.ProxyOptions, &ch.CacheOptions, &ch.ReplayOptions)
+}
+
+// writeSitemaps writes the sitemap of the crawled pages to --out
+func (o *SitemapOptions) writeSitemaps(results []crawler.PageResult) ([]string, error) {
+	return crawler.WriteSitemaps(o.Out, results, crawler.SitemapOptions{
+		BaseURL:    o.BaseURL,
+		ChangeFreq: o.ChangeFreq,
+		Priority:   o.Priority,
+	})
+}
+
+// writeGraph exports the link graph to --graph
+func (o *GraphOptions) writeGraph(graph crawler.LinkGraph, silent bool) error {
+	if o.Graph == "" {
+		return nil
+	}
+	format := o.GraphFormat
+	if format == "" {
+		switch strings.ToLower(filepath.Ext(o.Graph)) {
+		case ".dot", ".gv":
+			format = "dot"
+		case ".graphml", ".xml":
+			format = "graphml"
+		default:
+			format = "json"
+		}
+	}
+	f, err := os.Create(o.Graph)
+	if err != nil {
+		return fmt.Errorf("failed to write link graph: %w", err)
+	}
+	switch format {
+	case "dot":
+		err = graph.WriteDOT(f)
+	case "graphml":
+		err = graph.WriteGraphML(f)
+	default:
+		err = graph.WriteJSON(f)
+	}
+	if closeErr := f.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		return fmt.Errorf("failed to write link graph: %w", err)
+	}
+	if !silent {
+		log.Printf("Wrote link graph of %d pages and %d links to %s (%d orphan pages)",
+			len(graph.Pages), len(graph.Edges), o.Graph, len(graph.Orphans()))
+	}
+	return nil
+}
+
+// blockURLs combines --block-urls with the entries of --block-list
+func (b *BlockingOptions) blockURLs() ([]string, error) {
+	patterns := append([]string{}, b.BlockURLs...)
+	if b.BlockList == "" {
+		return patterns, nil
+	}
+	data, err := os.ReadFile(b.BlockList)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read block list: %w", err)
+	}
+	for _, line := range strings.Split(string(data), "\n") {
+		if i := strings.Index(line, "#"); i != -1 {
+			line = line[:i]
+		}
+		fields := strings.Fields(line)
+		if len(fields) == 0 {
+			continue
+		}
+		// hosts file entries look like "0.0.0.0 ads.example.com"
+		patterns = append(patterns, fields[len(fields)-1])
+	}
+	return patterns, nil
+}
+
+// headers parses the --header flags
+func (r *RequestOptions) headers() (map[string]string, error) {
+	headers := make(map[string]string, len(r.Header))
+	for _, header := range r.Header {
+		name, value, ok := strings.Cut(header, ":")
+		name = strings.TrimSpace(name)
+		if !ok || name == "" {
+			return nil, fmt.Errorf("invalid header %q, expected NAME: VALUE", header)
+		}
+		headers[name] = strings.TrimSpace(value)
+	}
+	return headers, nil
+}
+
+// credentials parses the --auth, --bearer and --login-* flags
+func (a *AuthOptions) credentials() (map[string]crawler.Credentials, *crawler.FormLogin, error) {
+	auth := make(map[string]crawler.Credentials)
+	for _, value := range a.Auth {
+		domain, userinfo, ok := strings.Cut(value, "=")
+		user, password, hasPassword := strings.Cut(userinfo, ":")
+		if !ok || domain == "" || !hasPassword {
+			return nil, nil, fmt.Errorf("invalid --auth %q, expected DOMAIN=USER:PASSWORD", value)
+		}
+		auth[domain] = crawler.Credentials{Username: user, Password: password}
+	}
+	for _, value := range a.Bearer {
+		domain, token, ok := strings.Cut(value, "=")
+		if !ok || domain == "" || token == "" {
+			return nil, nil, fmt.Errorf("invalid --bearer %q, expected DOMAIN=TOKEN", value)
+		}
+		auth[domain] = crawler.Credentials{Token: token}
+	}
+	if a.LoginURL == "" {
+		if len(a.LoginField) > 0 || a.LoginSuccess != "" {
+			return nil, nil, fmt.Errorf("--login-field and --login-success need --login-url")
+		}
+		return auth, nil, nil
+	}
+	login := &crawler.FormLogin{URL: a.LoginURL, Fields: make(map[string]string), Success: a.LoginSuccess}
+	for _, value := range a.LoginField {
+		name, fieldValue, ok := strings.Cut(value, "=")
+		if !ok || name == "" {
+			return nil, nil, fmt.Errorf("invalid --login-field %q, expected NAME=VALUE", value)
+		}
+		login.Fields[name] = fieldValue
+	}
+	return auth, login, nil
+}
+
+// proxyRules parses the --proxy-rule flags
+func (p *ProxyOptions) proxyRules() (map[string]string, error) {
+	rules := make(map[string]string, len(p.ProxyRule))
+	for _, value := range p.ProxyRule {
+		domain, proxy, ok := strings.Cut(value, "=")
+		if !ok || domain == "" || proxy == "" {
+			return nil, fmt.Errorf("invalid --proxy-rule %q, expected DOMAIN=PROXY or DOMAIN=direct", value)
+		}
+		rules[domain] = proxy
+	}
+	return rules, nil
+}
+
+// logCaptures reports how many pages had screenshots or PDFs saved
+func (o *CaptureOptions) logCaptures(results []crawler.PageResult) {
+	if o.Screenshot == "" && !o.PDF {
+		return
+	}
+	captured, failed := 0, 0
+	for _, r := range results {
+		if r.Screenshot != "" || r.PDF != "" {
+			captured++
+		}
+		if r.CaptureError != "" {
+			failed++
+		}
+	}
+	log.Printf("Saved captures for %d pages to %s", captured, o.OutputDir)
+	if failed > 0 {
+		log.Printf("Failed to capture %d pages", failed)
+	}
+}
+
+// logSkipped reports how many pages were not parsed, by reason
+func (o *ContentOptions) logSkipped(results []crawler.PageResult) {
+	reasons := map[string]int{}
+	for _, r := range results {
+		if r.Skipped != "" {
+			reasons[r.Skipped]++
+		}
+	}
+	for _, reason := range slices.Sorted(maps.Keys(reasons)) {
+		log.Printf("Skipped %d pages: %s", reasons[reason], reason)
+	}
+}
+
+// logDirectives reports the pages and links left out by robots directives
+func (s *CrawlSettings) logDirectives(stats crawler.Stats) {
+	if !s.RobotsDirectives {
+		return
+	}
+	log.Printf("Robots directives: %d noindex pages, %d nofollow pages, %d nofollow links",
+		stats.Noindex, stats.Nofollow, stats.NofollowLinks)
+}
+
+// logCache reports how many requests the cache answered
+func (o *CacheOptions) logCache(stats crawler.Stats) {
+	if o.CacheDir == "" {
+		return
+	}
+	log.Printf("Cache: %d hits, %d misses", stats.CacheHits, stats.CacheMisses)
+}
+
+// Validate rejects --only-changed without --validators, which has nothing to compare pages
+// with and would quietly download every page
+func (s *StateOptions) Validate() error {
+	if s.OnlyChanged && s.Validators == "" {
+		return fmt.Errorf("--only-changed needs --validators")
+	}
+	return nil
+}
+
+// logChanges reports how many pages are new, changed, unchanged or gone since the last crawl
+func (s *StateOptions) logChanges(results []crawler.PageResult) {
+	if s.Validators == "" {
+		return
+	}
+	counts := map[string]int{}
+	for _, r := range results {
+		counts[r.Status]++
+	}
+	log.Printf("Pages since last crawl: %d new, %d changed, %d unchanged, %d gone",
+		counts["new"], counts["changed"], counts["unchanged"], counts["gone"])
+}
+
+// stopOnInterrupt stops the crawl on the first Ctrl-C so its state is saved,
+// a second Ctrl-C exits immediately. The returned func removes the handler.
+func (s *StateOptions) stopOnInterrupt(cr *crawler.Crawler, silent bool) func() {
+	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, os.Interrupt, syscall.SIGTERM)
+	done := make(chan struct{})
+	go func() {
+		select {
+		case <-signals:
+		case <-done:
+			return
+		}
+		if !silent {
+			if s.StateDir != "" {
+				log.Printf("Stopping, saving state to %s (Ctrl-C again to quit)", s.StateDir)
+			} else {
+				log.Printf("Stopping (Ctrl-C again to quit)")
+			}
+		}
+		cr.Stop()
+		select {
+		case <-signals:
+			os.Exit(130)
+		case <-done:
+		}
+	}()
+	return func() {
+		signal.Stop(signals)
+		close(done)
+	}
 }
 
 // expandURLs handles comma-separated URLs in addition to multiple --urls flags
@@ -228,7 +972,8 @@ func Execute() (interface{}, error) {
 		kong.Description("A Golang library and CLI to crawl the web for links and information."),
 		kong.UsageOnError(),
 		kong.Vars{
-			"version": getVersion(),
+			"version":           getVersion(),
+			"chromium_revision": strconv.Itoa(browser.Revision),
 		},
 		kong.ConfigureHelp(kong.HelpOptions{
 			Compact: true,
@@ -0,0 +1,280 @@
+package crawler
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"slices"
+	"strings"
+
+	"golang.org/x/net/html"
+)
+
+// maxLoginSize limits the login pages read by the form login.
+const maxLoginSize = 10 * 1024 * 1024
+
+// Credentials authenticate the requests sent to a domain, with a bearer Token
+// when it is set and HTTP basic auth otherwise.
+type Credentials struct {
+	Username string
+	Password string
+	Token    string
+}
+
+// FormLogin logs in through an HTML form before the crawl starts. The form on URL
+// is submitted with its hidden fields, such as CSRF tokens, and Fields filled in.
+type FormLogin struct {
+	URL    string
+	Fields map[string]string
+	// Success is text the page shown after logging in must contain,
+	// any non-error status is accepted when empty
+	Success string
+}
+
+// authorization returns the Authorization header for requests to u, or "" when
+// no credentials are configured for its domain. The most specific domain wins.
+func (c *Crawler) authorization(u *url.URL) string {
+	host := strings.ToLower(u.Hostname())
+	match := ""
+	var creds Credentials
+	for domain, cr := range c.Auth {
+		domain = strings.ToLower(domain)
+		if domain != strings.ToLower(u.Host) && !domainMatch(host, domain) {
+			continue
+		}
+		if len(domain) > len(match) {
+			match, creds = domain, cr
+		}
+	}
+	switch {
+	case match == "":
+		return ""
+	case creds.Token != "":
+		return "Bearer " + creds.Token
+	}
+	req := http.Request{Header: http.Header{}}
+	req.SetBasicAuth(creds.Username, creds.Password)
+	return req.Header.Get("Authorization")
+}
+
+// pageAuthorization is authorization for the browser, which passes urls as strings.
+func (c *Crawler) pageAuthorization(pageURL string) string {
+	u, err := url.Parse(pageURL)
+	if err != nil {
+		return ""
+	}
+	return c.authorization(u)
+}
+
+// login runs the form login so every worker, and the browser, starts with its session cookies.
+func (c *Crawler) login() error {
+	if c.Login == nil || c.Login.URL == "" {
+		return nil
+	}
+	if c.Jar == nil {
+		return errors.New("form login needs a cookie jar to keep the session in")
+	}
+	if !c.Silent {
+		fmt.Println("logging in at", c.Login.URL)
+	}
+	req, err := http.NewRequest(http.MethodGet, c.Login.URL, nil)
+	if err != nil {
+		return fmt.Errorf("invalid login url %s: %w", c.Login.URL, err)
+	}
+	resp, body, err := c.loginRequest(req)
+	if err != nil {
+		return err
+	}
+	action, method, values := loginForm(resp.Request.URL.String(), body, c.Login.Fields)
+	for name, value := range c.Login.Fields {
+		values.Set(name, value)
+	}
+	if method == http.MethodGet {
+		target, err := url.Parse(action)
+		if err != nil {
+			return fmt.Errorf("invalid login form action %s: %w", action, err)
+		}
+		target.RawQuery = values.Encode()
+		req, err = http.NewRequest(http.MethodGet, target.String(), nil)
+		if err != nil {
+			return fmt.Errorf("invalid login form action %s: %w", action, err)
+		}
+	} else {
+		req, err = http.NewRequest(http.MethodPost, action, strings.NewReader(values.Encode()))
+		if err != nil {
+			return fmt.Errorf("invalid login form action %s: %w", action, err)
+		}
+		req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	}
+	resp, body, err = c.loginRequest(req)
+	if err != nil {
+		return err
+	}
+	if resp.StatusCode >= http.StatusBadRequest {
+		return fmt.Errorf("login failed: HTTP %d %s for %s", resp.StatusCode, resp.Status, action)
+	}
+	if c.Login.Success != "" && !strings.Contains(body, c.Login.Success) {
+		return fmt.Errorf("login failed: %q not found on %s", c.Login.Success, resp.Request.URL)
+	}
+	return nil
+}
+
+// loginRequest sends a login request and reads the page it ends up on.
+func (c *Crawler) loginRequest(req *http.Request) (*http.Response, string, error) {
+	resp, err := c.do(req)
+	if err != nil {
+		return nil, "", fmt.Errorf("login failed: network error fetching %s: %w", req.URL, err)
+	}
+	defer func() {
+		_ = resp.Body.Close()
+	}()
+	body, err := io.ReadAll(io.LimitReader(resp.Body, maxLoginSize))
+	if err != nil {
+		return nil, "", fmt.Errorf("login failed: %w", err)
+	}
+	return resp, string(body), nil
+}
+
+// loginForm finds the login form on a page: the first form with one of the given
+// fields, or else with a password input. It returns the absolute action, the method
+// and the values the form would submit. Pages without a form are posted to directly.
+func loginForm(pageURL, htmlContent string, fields map[string]string) (string, string, url.Values) {
+	doc, err := html.Parse(strings.NewReader(htmlContent))
+	if err != nil {
+		return pageURL, http.MethodPost, url.Values{}
+	}
+	forms := []*html.Node{}
+	var find func(*html.Node)
+	find = func(n *html.Node) {
+		if n.Type == html.ElementNode && n.Data == "form" {
+			forms = append(forms, n)
+			return
+		}
+		for child := n.FirstChild; child != nil; child = child.NextSibling {
+			find(child)
+		}
+	}
+	find(doc)
+	var form *html.Node
+	var values url.Values
+	for _, f := range forms {
+		v, password := formValues(f)
+		matches := false
+		for name := range fields {
+			if _, ok := v[name]; ok {
+				matches = true
+			}
+		}
+		if matches || password && form == nil {
+			form, values = f, v
+		}
+		if matches {
+			break
+		}
+	}
+	if form == nil {
+		return pageURL, http.MethodPost, url.Values{}
+	}
+	action := pageURL
+	method := http.MethodPost
+	for _, attr := range form.Attr {
+		switch attr.Key {
+		case "action":
+			if strings.TrimSpace(attr.Val) != "" {
+				action = toAbsoluteURL(pageURL, strings.TrimSpace(attr.Val))
+			}
+		case "method":
+			if strings.EqualFold(strings.TrimSpace(attr.Val), "get") {
+				method = http.MethodGet
+			}
+		}
+	}
+	return action, method, values
+}
+
+// formValues returns the values a form submits as it is, and whether it has a password input.
+func formValues(form *html.Node) (url.Values, bool) {
+	values := url.Values{}
+	password := false
+	var f func(*html.Node)
+	f = func(n *html.Node) {
+		if n.Type == html.ElementNode {
+			name, value, typ := attrValue(n, "name"), attrValue(n, "value"), strings.ToLower(attrValue(n, "type"))
+			switch n.Data {
+			case "input":
+				if typ == "password" {
+					password = true
+				}
+				checkable := typ == "checkbox" || typ == "radio"
+				skipped := slices.Contains([]string{"submit", "button", "image", "reset", "file"}, typ)
+				if name != "" && !skipped && (!checkable || hasAttr(n, "checked")) {
+					if checkable && value == "" {
+						value = "on"
+					}
+					values.Add(name, value)
+				}
+			case "textarea":
+				if name != "" {
+					values.Add(name, nodeText(n))
+				}
+			case "select":
+				if name != "" {
+					values.Add(name, selectedOption(n))
+				}
+				return
+			}
+		}
+		for child := n.FirstChild; child != nil; child = child.NextSibling {
+			f(child)
+		}
+	}
+	f(form)
+	return values, password
+}
+
+// selectedOption returns the value of the selected option of a select, or of its first option.
+func selectedOption(n *html.Node) string {
+	first, found := "", false
+	var f func(*html.Node) (string, bool)
+	f = func(n *html.Node) (string, bool) {
+		if n.Type == html.ElementNode && n.Data == "option" {
+			value := attrValue(n, "value")
+			if !hasAttr(n, "value") {
+				value = strings.TrimSpace(nodeText(n))
+			}
+			if hasAttr(n, "selected") {
+				return value, true
+			}
+			if !found {
+				first, found = value, true
+			}
+		}
+		for child := n.FirstChild; child != nil; child = child.NextSibling {
+			if value, ok := f(child); ok {
+				return value, true
+			}
+		}
+		return "", false
+	}
+	if value, ok := f(n); ok {
+		return value
+	}
+	return first
+}
+
+func attrValue(n *html.Node, key string) string {
+	for _, attr := range n.Attr {
+		if attr.Key == key {
+			return attr.Val
+		}
+	}
+	return ""
+}
+
+func hasAttr(n *html.Node, key string) bool {
+	return slices.ContainsFunc(n.Attr, func(attr html.Attribute) bool {
+		return attr.Key == key
+	})
+}
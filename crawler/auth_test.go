@@ -0,0 +1,121 @@
+package crawler
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestAuthorization(t *testing.T) {
+	c := NewCrawler()
+	c.Auth = map[string]Credentials{
+		"example.com":         {Username: "user", Password: "pass"},
+		"api.example.com":     {Token: "abc"},
+		"intranet.local:8080": {Token: "port"},
+	}
+	tests := []struct {
+		url  string
+		want string
+	}{
+		{"https://example.com/", "Basic dXNlcjpwYXNz"},
+		{"https://www.example.com/page", "Basic dXNlcjpwYXNz"},
+		{"https://api.example.com/v1", "Bearer abc"},
+		{"https://v2.api.example.com/v1", "Bearer abc"},
+		{"http://intranet.local:8080/", "Bearer port"},
+		{"http://intranet.local/", ""},
+		{"https://notexample.com/", ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.url, func(t *testing.T) {
+			u, _ := url.Parse(tt.url)
+			assert.Equal(t, tt.want, c.authorization(u))
+		})
+	}
+}
+
+func TestBasicAuthCrawl(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if user, pass, ok := r.BasicAuth(); !ok || user != "admin" || pass != "secret" {
+			w.Header().Set("WWW-Authenticate", `Basic realm="staging"`)
+			http.Error(w, "unauthorized", http.StatusUnauthorized)
+			return
+		}
+		_, _ = w.Write([]byte(`<a href="/private">private</a>`))
+	}))
+	defer server.Close()
+	u, _ := url.Parse(server.URL)
+
+	c := NewCrawler()
+	c.Silent = true
+	c.Auth = map[string]Credentials{u.Hostname(): {Username: "admin", Password: "secret"}}
+	results, err := c.Crawl(server.URL)
+	assert.NoError(t, err)
+	assert.Len(t, results, 2)
+}
+
+func TestFormLogin(t *testing.T) {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/login", func(w http.ResponseWriter, r *http.Request) {
+		if r.Method == http.MethodGet {
+			_, _ = w.Write([]byte(`<form action="/search"><input name="q"></form>
+				<form method="post" action="/session">
+					<input type="hidden" name="csrf" value="token123">
+					<input name="user"><input type="password" name="pass">
+					<input type="checkbox" name="remember" checked>
+					<select name="lang"><option value="en">English</option><option value="de" selected>Deutsch</option></select>
+					<input type="submit" name="go" value="Log in">
+				</form>`))
+			return
+		}
+		http.NotFound(w, r)
+	})
+	mux.HandleFunc("/session", func(w http.ResponseWriter, r *http.Request) {
+		_ = r.ParseForm()
+		if r.Method != http.MethodPost || r.Form.Get("csrf") != "token123" || r.Form.Get("user") != "bob" ||
+			r.Form.Get("pass") != "hunter2" || r.Form.Get("remember") != "on" || r.Form.Get("lang") != "de" || r.Form.Has("go") {
+			http.Error(w, "bad login", http.StatusForbidden)
+			return
+		}
+		http.SetCookie(w, &http.Cookie{Name: "session", Value: "ok", Path: "/"})
+		http.Redirect(w, r, "/", http.StatusSeeOther)
+	})
+	mux.HandleFunc("/", func(w http.ResponseWriter, r *http.Request) {
+		if cookie, err := r.Cookie("session"); err != nil || cookie.Value != "ok" {
+			http.Redirect(w, r, "/login", http.StatusFound)
+			return
+		}
+		w.Header().Set("Content-Type", "text/html")
+		_, _ = w.Write([]byte(`Welcome bob <a href="/members">members</a>`))
+	})
+	server := httptest.NewServer(mux)
+	defer server.Close()
+
+	tests := []struct {
+		name    string
+		fields  map[string]string
+		success string
+		wantErr string
+	}{
+		{"logged in", map[string]string{"user": "bob", "pass": "hunter2"}, "Welcome bob", ""},
+		{"wrong password", map[string]string{"user": "bob", "pass": "wrong"}, "Welcome bob", "HTTP 403"},
+		{"success text missing", map[string]string{"user": "bob", "pass": "hunter2"}, "Welcome alice", `"Welcome alice" not found`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := NewCrawler()
+			c.Silent = true
+			c.MaxDepth = 1
+			c.Login = &FormLogin{URL: server.URL + "/login", Fields: tt.fields, Success: tt.success}
+			results, err := c.Crawl(server.URL + "/")
+			if tt.wantErr != "" {
+				assert.ErrorContains(t, err, tt.wantErr)
+				return
+			}
+			assert.NoError(t, err)
+			assert.Contains(t, results[server.URL+"/"], "Welcome bob")
+		})
+	}
+}
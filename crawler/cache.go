@@ -0,0 +1,350 @@
+package crawler
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Cache stores HTTP responses between requests and crawls, by normalized url.
+// Implementations must be safe for concurrent use.
+type Cache interface {
+	Get(key string) ([]byte, bool)
+	Set(key string, value []byte) error
+	Delete(key string) error
+}
+
+// DiskCache is a Cache keeping each response in its own file in a directory.
+type DiskCache struct {
+	dir string
+}
+
+// NewDiskCache returns a cache in dir, creating the directory when needed.
+func NewDiskCache(dir string) (*DiskCache, error) {
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return nil, fmt.Errorf("failed to create cache directory: %w", err)
+	}
+	return &DiskCache{dir: dir}, nil
+}
+
+// Get returns the response stored for key.
+func (d *DiskCache) Get(key string) ([]byte, bool) {
+	data, err := os.ReadFile(d.path(key))
+	return data, err == nil
+}
+
+// Set stores a response for key.
+func (d *DiskCache) Set(key string, value []byte) error {
+	// write to a temporary file first so concurrent readers never see a partial response
+	tmp, err := os.CreateTemp(d.dir, ".cache-*")
+	if err != nil {
+		return err
+	}
+	_, err = tmp.Write(value)
+	if closeErr := tmp.Close(); err == nil {
+		err = closeErr
+	}
+	if err == nil {
+		err = os.Rename(tmp.Name(), d.path(key))
+	}
+	if err != nil {
+		_ = os.Remove(tmp.Name())
+	}
+	return err
+}
+
+// Delete removes the response stored for key.
+func (d *DiskCache) Delete(key string) error {
+	err := os.Remove(d.path(key))
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil
+	}
+	return err
+}
+
+func (d *DiskCache) path(key string) string {
+	sum := sha256.Sum256([]byte(key))
+	return filepath.Join(d.dir, hex.EncodeToString(sum[:]))
+}
+
+// cacheEntry is a stored response with what is needed to work out its age and to match it to requests.
+type cacheEntry struct {
+	URL          string      `json:"url"`
+	StatusCode   int         `json:"status_code"`
+	Status       string      `json:"status"`
+	Header       http.Header `json:"header"`
+	Body         []byte      `json:"body"`
+	Vary         http.Header `json:"vary,omitempty"` // the request headers named by Vary
+	RequestTime  time.Time   `json:"request_time"`
+	ResponseTime time.Time   `json:"response_time"`
+}
+
+// openCache sets up the cache from Cache or CacheDir the first time a request is sent.
+func (c *Crawler) openCache() error {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	if c.cache != nil || (c.Cache == nil && c.CacheDir == "") {
+		return nil
+	}
+	if c.Cache != nil {
+		c.cache = c.Cache
+		return nil
+	}
+	cache, err := NewDiskCache(c.CacheDir)
+	if err != nil {
+		return err
+	}
+	c.cache = cache
+	return nil
+}
+
+// transport returns the round tripper requests are sent with: the cache, when one is set,
+// in front of the Record and Replay cassettes, in front of base.
+func (c *Crawler) transport(base http.RoundTripper) http.RoundTripper {
+	c.mutex.Lock()
+	cache, tape := c.cache, c.tape
+	c.mutex.Unlock()
+	rt := base
+	if tape != nil {
+		rt = &tapeTransport{tape: tape, next: rt}
+	}
+	if cache != nil {
+		rt = &cacheTransport{crawler: c, cache: cache, next: rt}
+	}
+	return rt
+}
+
+// cacheTransport answers GET requests from the cache while the stored response is fresh,
+// revalidates it with the server once it is stale, and stores new responses that may be cached.
+type cacheTransport struct {
+	crawler *Crawler
+	cache   Cache
+	next    http.RoundTripper
+}
+
+func (t *cacheTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	key := t.crawler.normalize(req.URL.String())
+	if req.Method != http.MethodGet {
+		resp, err := t.next.RoundTrip(req)
+		// unsafe methods that succeed invalidate what is stored for the url
+		if err == nil && req.Method != http.MethodHead && resp.StatusCode < 400 {
+			_ = t.cache.Delete(key)
+		}
+		return resp, err
+	}
+	entry, stored := t.load(key, req)
+	now := time.Now()
+	if stored && t.fresh(entry, req, now) {
+		t.count(true)
+		return entry.response(req, now), nil
+	}
+	// requests made conditional by the caller, for ValidatorsFile, keep their own validators
+	callerConditional := req.Header.Get("If-None-Match") != "" || req.Header.Get("If-Modified-Since") != ""
+	outgoing := req
+	if stored && !callerConditional {
+		etag, lastModified := entry.Header.Get("ETag"), entry.Header.Get("Last-Modified")
+		if etag != "" || lastModified != "" {
+			outgoing = req.Clone(req.Context())
+			if etag != "" {
+				outgoing.Header.Set("If-None-Match", etag)
+			}
+			if lastModified != "" {
+				outgoing.Header.Set("If-Modified-Since", lastModified)
+			}
+		}
+	}
+	requestTime := time.Now()
+	resp, err := t.next.RoundTrip(outgoing)
+	if err != nil {
+		return nil, err
+	}
+	responseTime := time.Now()
+	if resp.StatusCode == http.StatusNotModified && stored && (outgoing != req || sameETag(entry, resp.Header)) {
+		entry.freshen(resp.Header, requestTime, responseTime)
+		t.save(key, entry)
+		t.count(true)
+		if outgoing == req {
+			return resp, nil // the caller's own conditional request
+		}
+		_ = resp.Body.Close()
+		return entry.response(req, responseTime), nil
+	}
+	t.count(false)
+	if t.storable(req, resp) {
+		entry := &cacheEntry{
+			URL:          key,
+			StatusCode:   resp.StatusCode,
+			Status:       resp.Status,
+			Header:       storedHeader(resp.Header),
+			Vary:         varyHeaders(req, resp.Header),
+			RequestTime:  requestTime,
+			ResponseTime: responseTime,
+		}
+		// the body is stored once it was read to the end, pages skipped part way are not kept
+		resp.Body = &cachingBody{ReadCloser: resp.Body, done: func(body []byte) {
+			entry.Body = body
+			t.save(key, entry)
+		}}
+	}
+	return resp, nil
+}
+
+// load returns the stored response for a request, when its Vary headers match.
+func (t *cacheTransport) load(key string, req *http.Request) (*cacheEntry, bool) {
+	data, ok := t.cache.Get(key)
+	if !ok {
+		return nil, false
+	}
+	entry := &cacheEntry{}
+	if err := json.Unmarshal(data, entry); err != nil || entry.URL != key {
+		return nil, false
+	}
+	if !varyMatches(entry, req) {
+		return nil, false
+	}
+	return entry, true
+}
+
+// save stores an entry, a cache that can't be written to only costs the next crawl a request.
+func (t *cacheTransport) save(key string, entry *cacheEntry) {
+	data, err := json.Marshal(entry)
+	if err == nil {
+		err = t.cache.Set(key, data)
+	}
+	if err != nil && !t.crawler.Silent {
+		fmt.Printf("Warning: failed to cache %s: %v\n", key, err)
+	}
+}
+
+// fresh reports whether a stored response can be used without asking the server. With
+// CacheTTL set any response younger than it is used.
+func (t *cacheTransport) fresh(entry *cacheEntry, req *http.Request, now time.Time) bool {
+	if t.crawler.CacheTTL > 0 {
+		return now.Sub(entry.ResponseTime) < t.crawler.CacheTTL
+	}
+	return isFresh(entry, req, now)
+}
+
+// storable reports whether a response may be stored. With CacheTTL set every complete
+// response is, apart from server errors and refused logins, which would otherwise outlive
+// the credentials or cookies that fix them.
+func (t *cacheTransport) storable(req *http.Request, resp *http.Response) bool {
+	if t.crawler.CacheTTL > 0 {
+		switch resp.StatusCode {
+		case http.StatusPartialContent, http.StatusUnauthorized, http.StatusForbidden:
+			return false
+		}
+		return resp.StatusCode < 500 && resp.Header.Get("Vary") != "*"
+	}
+	return isStorable(req, resp)
+}
+
+// count adds a cache hit or miss to the crawl stats.
+func (t *cacheTransport) count(hit bool) {
+	t.crawler.mutex.Lock()
+	defer t.crawler.mutex.Unlock()
+	if hit {
+		t.crawler.stats.CacheHits++
+	} else {
+		t.crawler.stats.CacheMisses++
+	}
+}
+
+// response builds the response served from the cache, with its Age. Conditional requests
+// whose validators match are answered with 304 Not Modified.
+func (e *cacheEntry) response(req *http.Request, now time.Time) *http.Response {
+	header := e.Header.Clone()
+	header.Set("Age", strconv.FormatInt(int64(currentAge(e, now)/time.Second), 10))
+	resp := &http.Response{
+		Status:        e.Status,
+		StatusCode:    e.StatusCode,
+		Proto:         "HTTP/1.1",
+		ProtoMajor:    1,
+		ProtoMinor:    1,
+		Header:        header,
+		Body:          io.NopCloser(bytes.NewReader(e.Body)),
+		ContentLength: int64(len(e.Body)),
+		Request:       req,
+	}
+	if e.StatusCode == http.StatusOK && notModified(e, req) {
+		resp.Status = "304 Not Modified"
+		resp.StatusCode = http.StatusNotModified
+		resp.Header.Del("Content-Length")
+		resp.Body = http.NoBody
+		resp.ContentLength = 0
+	}
+	return resp
+}
+
+// freshen updates a stored response with the headers of a 304 Not Modified revalidating it.
+func (e *cacheEntry) freshen(header http.Header, requestTime, responseTime time.Time) {
+	for name, values := range header {
+		if name != "Content-Length" && name != "Transfer-Encoding" && name != "Set-Cookie" {
+			e.Header[name] = values
+		}
+	}
+	e.RequestTime = requestTime
+	e.ResponseTime = responseTime
+}
+
+// storedHeader returns the response headers kept in the cache. Set-Cookie is left out, the
+// jar already has the cookies and replaying them would undo later changes to them.
+func storedHeader(header http.Header) http.Header {
+	header = header.Clone()
+	header.Del("Set-Cookie")
+	return header
+}
+
+// cachingBody keeps a copy of a response body and hands it over once it was read to the end.
+type cachingBody struct {
+	io.ReadCloser
+	buf  bytes.Buffer
+	done func(body []byte)
+}
+
+func (b *cachingBody) Read(p []byte) (int, error) {
+	n, err := b.ReadCloser.Read(p)
+	b.buf.Write(p[:n])
+	if err == io.EOF && b.done != nil {
+		b.done(b.buf.Bytes())
+		b.done = nil
+	}
+	return n, err
+}
+
+// notModified reports whether a conditional request matches a stored response.
+func notModified(e *cacheEntry, req *http.Request) bool {
+	if match := req.Header.Get("If-None-Match"); match != "" {
+		etag := strings.TrimPrefix(e.Header.Get("ETag"), "W/")
+		for _, candidate := range strings.Split(match, ",") {
+			candidate = strings.TrimPrefix(strings.TrimSpace(candidate), "W/")
+			if candidate == "*" || (etag != "" && candidate == etag) {
+				return true
+			}
+		}
+		return false
+	}
+	since, err := http.ParseTime(req.Header.Get("If-Modified-Since"))
+	if err != nil {
+		return false
+	}
+	modified, err := http.ParseTime(e.Header.Get("Last-Modified"))
+	return err == nil && !modified.After(since)
+}
+
+// sameETag reports whether a 304 Not Modified is about the stored response.
+func sameETag(e *cacheEntry, header http.Header) bool {
+	etag := header.Get("ETag")
+	return etag == "" || strings.TrimPrefix(etag, "W/") == strings.TrimPrefix(e.Header.Get("ETag"), "W/")
+}
@@ -0,0 +1,209 @@
+package crawler
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestFreshness(t *testing.T) {
+	received := time.Date(2026, 3, 1, 12, 0, 0, 0, time.UTC)
+	date := received.Format(http.TimeFormat)
+	tests := []struct {
+		name        string
+		status      int
+		header      http.Header
+		request     http.Header
+		wantStore   bool
+		wantFreshAt time.Duration // how long after receiving it the response is still fresh, 0 for never
+	}{
+		{"max-age", 200, http.Header{"Cache-Control": {"public, max-age=600"}, "Date": {date}}, nil, true, 10 * time.Minute},
+		{"age header counts", 200, http.Header{"Cache-Control": {"max-age=600"}, "Age": {"500"}, "Date": {date}}, nil, true, 100 * time.Second},
+		{"expires", 200, http.Header{"Expires": {received.Add(time.Hour).Format(http.TimeFormat)}, "Date": {date}}, nil, true, time.Hour},
+		{"invalid expires", 200, http.Header{"Expires": {"0"}, "Date": {date}}, nil, true, 0},
+		{"heuristic", 200, http.Header{"Last-Modified": {received.Add(-100 * time.Hour).Format(http.TimeFormat)}, "Date": {date}}, nil, true, 10 * time.Hour},
+		{"no validators", 200, http.Header{"Date": {date}}, nil, true, 0},
+		{"no-cache", 200, http.Header{"Cache-Control": {"no-cache, max-age=600"}, "Date": {date}}, nil, true, 0},
+		{"no-store", 200, http.Header{"Cache-Control": {"no-store"}}, nil, false, 0},
+		{"request no-store", 200, http.Header{"Cache-Control": {"max-age=600"}}, http.Header{"Cache-Control": {"no-store"}}, false, 0},
+		{"request no-cache", 200, http.Header{"Cache-Control": {"max-age=600"}, "Date": {date}}, http.Header{"Pragma": {"no-cache"}}, true, 0},
+		{"vary star", 200, http.Header{"Cache-Control": {"max-age=600"}, "Vary": {"*"}}, nil, false, 0},
+		{"302 without freshness", 302, http.Header{"Date": {date}}, nil, false, 0},
+		{"302 with max-age", 302, http.Header{"Cache-Control": {"max-age=60"}, "Date": {date}}, nil, true, time.Minute},
+		{"server error", 500, http.Header{"Date": {date}}, nil, false, 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req, _ := http.NewRequest(http.MethodGet, "https://example.com/", nil)
+			if tt.request != nil {
+				req.Header = tt.request
+			}
+			resp := &http.Response{StatusCode: tt.status, Header: tt.header}
+			assert.Equal(t, tt.wantStore, isStorable(req, resp))
+			if !tt.wantStore {
+				return
+			}
+			e := &cacheEntry{StatusCode: tt.status, Header: tt.header, RequestTime: received, ResponseTime: received}
+			if tt.wantFreshAt > 0 {
+				assert.True(t, isFresh(e, req, received.Add(tt.wantFreshAt-time.Second)))
+			}
+			assert.False(t, isFresh(e, req, received.Add(tt.wantFreshAt)))
+		})
+	}
+}
+
+func TestVary(t *testing.T) {
+	req, _ := http.NewRequest(http.MethodGet, "https://example.com/", nil)
+	req.Header.Set("Accept-Language", "de")
+	header := http.Header{"Vary": {"Accept-Language, Accept-Encoding"}}
+	e := &cacheEntry{Header: header, Vary: varyHeaders(req, header)}
+	assert.True(t, varyMatches(e, req))
+	other := req.Clone(req.Context())
+	other.Header.Set("Accept-Language", "fr")
+	assert.False(t, varyMatches(e, other))
+	other.Header.Set("Accept-Language", "de")
+	other.Header.Set("Accept-Encoding", "br")
+	assert.False(t, varyMatches(e, other))
+}
+
+func TestCacheCrawl(t *testing.T) {
+	var mutex sync.Mutex
+	requests := map[string]int{}
+	revalidated := 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mutex.Lock()
+		requests[r.URL.Path]++
+		mutex.Unlock()
+		w.Header().Set("Content-Type", "text/html")
+		switch r.URL.Path {
+		case "/":
+			w.Header().Set("Cache-Control", "max-age=3600")
+			_, _ = w.Write([]byte(`<a href="/etag">etag</a><a href="/nostore">nostore</a><a href="/plain">plain</a>`))
+		case "/etag":
+			w.Header().Set("Cache-Control", "no-cache")
+			w.Header().Set("ETag", `"v1"`)
+			if r.Header.Get("If-None-Match") == `"v1"` {
+				mutex.Lock()
+				revalidated++
+				mutex.Unlock()
+				w.WriteHeader(http.StatusNotModified)
+				return
+			}
+			_, _ = w.Write([]byte(`etag`))
+		case "/nostore":
+			w.Header().Set("Cache-Control", "no-store")
+			_, _ = w.Write([]byte(`nostore`))
+		default:
+			_, _ = w.Write([]byte(`plain`))
+		}
+	}))
+	defer server.Close()
+	dir := t.TempDir()
+
+	crawl := func(ttl time.Duration) Stats {
+		c := NewCrawler()
+		c.Silent = true
+		c.CacheDir = dir
+		c.CacheTTL = ttl
+		pages, err := c.Crawl(server.URL + "/")
+		assert.NoError(t, err)
+		assert.Len(t, pages, 4)
+		assert.Equal(t, "etag", pages[server.URL+"/etag"])
+		return c.Stats()
+	}
+	assert.Equal(t, Stats{CacheMisses: 4}, crawl(0))
+	// the page is fresh, the etag page is revalidated and the others can't be reused
+	stats := crawl(0)
+	assert.Equal(t, Stats{CacheHits: 2, CacheMisses: 2}, stats)
+	mutex.Lock()
+	assert.Equal(t, map[string]int{"/": 1, "/etag": 2, "/nostore": 2, "/plain": 2}, requests)
+	assert.Equal(t, 1, revalidated)
+	mutex.Unlock()
+
+	// forcing the cache reuses whatever was stored and keeps every response, so the next
+	// crawl needs no requests at all
+	assert.Equal(t, Stats{CacheHits: 3, CacheMisses: 1}, crawl(time.Hour))
+	assert.Equal(t, Stats{CacheHits: 4}, crawl(time.Hour))
+
+	// collect shares the cache with crawl
+	c := NewCrawler()
+	c.Silent = true
+	c.CacheDir = dir
+	c.CacheTTL = time.Hour
+	_, err := c.Collect(server.URL + "/")
+	assert.NoError(t, err)
+	assert.Equal(t, Stats{CacheHits: 4}, c.Stats())
+	mutex.Lock()
+	defer mutex.Unlock()
+	assert.Equal(t, map[string]int{"/": 1, "/etag": 2, "/nostore": 3, "/plain": 2}, requests)
+}
+
+func TestCacheCookiesAndRefusals(t *testing.T) {
+	var mutex sync.Mutex
+	accounts := 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/html")
+		w.Header().Set("Cache-Control", "max-age=3600")
+		switch r.URL.Path {
+		case "/":
+			http.SetCookie(w, &http.Cookie{Name: "session", Value: "old", Path: "/"})
+			_, _ = w.Write([]byte(`<a href="/account">account</a>`))
+		case "/account":
+			mutex.Lock()
+			accounts++
+			mutex.Unlock()
+			if cookie, err := r.Cookie("session"); err != nil || cookie.Value != "new" {
+				w.WriteHeader(http.StatusForbidden)
+				_, _ = w.Write([]byte(`denied`))
+				return
+			}
+			_, _ = w.Write([]byte(`welcome`))
+		}
+	}))
+	defer server.Close()
+	dir := t.TempDir()
+
+	crawl := func(jar *CookieJar) map[string]string {
+		c := NewCrawler()
+		c.Silent = true
+		c.CacheDir = dir
+		c.CacheTTL = time.Hour
+		c.Jar = jar
+		pages, err := c.Crawl(server.URL + "/")
+		assert.NoError(t, err)
+		return pages
+	}
+	pages := crawl(NewCookieJar())
+	assert.Empty(t, pages[server.URL+"/account"])
+
+	// the stored page doesn't set its old cookie again and the refused page is asked for again
+	jar := NewCookieJar()
+	site, _ := url.Parse(server.URL)
+	jar.SetCookies(site, []*http.Cookie{{Name: "session", Value: "new", Path: "/"}})
+	pages = crawl(jar)
+	assert.Equal(t, "welcome", pages[server.URL+"/account"])
+	assert.Equal(t, "new", jar.Cookies(site)[0].Value)
+	mutex.Lock()
+	defer mutex.Unlock()
+	assert.Equal(t, 2, accounts)
+}
+
+func TestDiskCache(t *testing.T) {
+	cache, err := NewDiskCache(t.TempDir())
+	assert.NoError(t, err)
+	_, ok := cache.Get("https://example.com/")
+	assert.False(t, ok)
+	assert.NoError(t, cache.Set("https://example.com/", []byte("stored")))
+	data, ok := cache.Get("https://example.com/")
+	assert.True(t, ok)
+	assert.Equal(t, "stored", string(data))
+	assert.NoError(t, cache.Delete("https://example.com/"))
+	assert.NoError(t, cache.Delete("https://example.com/"))
+	_, ok = cache.Get("https://example.com/")
+	assert.False(t, ok)
+}
@@ -0,0 +1,226 @@
+package crawler
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strconv"
+	"sync"
+	"unicode/utf8"
+)
+
+// cassette is the file Record writes and Replay reads: the requests a crawl sent and the
+// responses it got, in the order they finished.
+type cassette struct {
+	Interactions []interaction `json:"interactions"`
+}
+
+// interaction is a recorded request and its response. Request headers are not kept so
+// credentials and the cookies sent don't end up in cassettes, response headers are kept as is.
+type interaction struct {
+	Request  recordedRequest  `json:"request"`
+	Response recordedResponse `json:"response"`
+}
+
+type recordedRequest struct {
+	Method string `json:"method"`
+	URL    string `json:"url"`
+}
+
+type recordedResponse struct {
+	StatusCode int         `json:"status_code"`
+	Status     string      `json:"status"`
+	Header     http.Header `json:"header"`
+	Body       string      `json:"body"`
+	Base64     bool        `json:"base64,omitempty"` // the body is base64, for bodies that aren't UTF-8
+}
+
+// tape replays the interactions of a cassette and records new ones.
+type tape struct {
+	mutex    sync.Mutex
+	replay   []interaction
+	played   map[string]int // times each request was replayed
+	record   bool
+	recorded []interaction
+}
+
+// loadCassette reads a cassette written by Record.
+func loadCassette(path string) ([]interaction, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load cassette: %w", err)
+	}
+	var cs cassette
+	if err := json.Unmarshal(data, &cs); err != nil {
+		return nil, fmt.Errorf("failed to load cassette %s: %w", path, err)
+	}
+	return cs.Interactions, nil
+}
+
+// openTape loads the Replay cassette and starts recording for Record the first time a
+// request is sent. Recording into the cassette being replayed keeps its interactions
+// and adds the requests it had no response for.
+func (c *Crawler) openTape() error {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	if c.tape != nil || (c.Record == "" && c.Replay == "") {
+		return nil
+	}
+	t := &tape{played: make(map[string]int), record: c.Record != ""}
+	if c.Replay != "" {
+		interactions, err := loadCassette(c.Replay)
+		if err != nil {
+			return err
+		}
+		t.replay = interactions
+		if samePath(c.Record, c.Replay) {
+			t.recorded = append(t.recorded, interactions...)
+		}
+	}
+	c.tape = t
+	return nil
+}
+
+// saveTape writes the interactions recorded for Record.
+func (c *Crawler) saveTape() error {
+	c.mutex.Lock()
+	t := c.tape
+	c.mutex.Unlock()
+	if t == nil || !t.record {
+		return nil
+	}
+	t.mutex.Lock()
+	data, err := json.MarshalIndent(cassette{Interactions: t.recorded}, "", "  ")
+	t.mutex.Unlock()
+	if err != nil {
+		return fmt.Errorf("failed to save cassette: %w", err)
+	}
+	if err := os.WriteFile(c.Record, append(data, '\n'), 0o644); err != nil {
+		return fmt.Errorf("failed to save cassette: %w", err)
+	}
+	return nil
+}
+
+// samePath reports whether two file names point to the same file.
+func samePath(a, b string) bool {
+	if a == "" || b == "" {
+		return false
+	}
+	absA, errA := filepath.Abs(a)
+	absB, errB := filepath.Abs(b)
+	return errA == nil && errB == nil && absA == absB
+}
+
+// tapeTransport answers requests from the replayed cassette and records the responses of
+// the others, which only go to the network while recording.
+type tapeTransport struct {
+	tape *tape
+	next http.RoundTripper
+}
+
+func (t *tapeTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	if resp, ok := t.tape.play(req); ok {
+		return resp, nil
+	}
+	if !t.tape.record {
+		return nil, fmt.Errorf("no recorded response for %s %s", req.Method, req.URL)
+	}
+	resp, err := t.next.RoundTrip(req)
+	if err != nil {
+		return nil, err
+	}
+	recorded := interaction{
+		Request: recordedRequest{Method: req.Method, URL: req.URL.String()},
+		Response: recordedResponse{
+			StatusCode: resp.StatusCode,
+			Status:     resp.Status,
+			Header:     resp.Header.Clone(),
+		},
+	}
+	// the body is recorded as far as it was read once it is closed, so pages the crawl
+	// skipped part way replay the same way
+	resp.Body = &recordingBody{ReadCloser: resp.Body, done: func(body []byte) {
+		if utf8.Valid(body) {
+			recorded.Response.Body = string(body)
+		} else {
+			recorded.Response.Body = base64.StdEncoding.EncodeToString(body)
+			recorded.Response.Base64 = true
+		}
+		t.tape.mutex.Lock()
+		t.tape.recorded = append(t.tape.recorded, recorded)
+		t.tape.mutex.Unlock()
+	}}
+	return resp, nil
+}
+
+// play returns the recorded response for a request. A request sent several times gets
+// the responses recorded for it in turn, the last one once they run out.
+func (t *tape) play(req *http.Request) (*http.Response, bool) {
+	key := req.Method + " " + req.URL.String()
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+	matches := []interaction{}
+	for _, i := range t.replay {
+		if i.Request.Method+" "+i.Request.URL == key {
+			matches = append(matches, i)
+		}
+	}
+	if len(matches) == 0 {
+		return nil, false
+	}
+	recorded := matches[min(t.played[key], len(matches)-1)].Response
+	t.played[key]++
+	body := []byte(recorded.Body)
+	if recorded.Base64 {
+		decoded, err := base64.StdEncoding.DecodeString(recorded.Body)
+		if err != nil {
+			return nil, false
+		}
+		body = decoded
+	}
+	header := recorded.Header.Clone()
+	if header == nil {
+		header = http.Header{}
+	}
+	length := int64(-1)
+	if n, err := strconv.ParseInt(recorded.Header.Get("Content-Length"), 10, 64); err == nil {
+		length = n
+	}
+	return &http.Response{
+		Status:        recorded.Status,
+		StatusCode:    recorded.StatusCode,
+		Proto:         "HTTP/1.1",
+		ProtoMajor:    1,
+		ProtoMinor:    1,
+		Header:        header,
+		Body:          io.NopCloser(bytes.NewReader(body)),
+		ContentLength: length,
+		Request:       req,
+	}, true
+}
+
+// recordingBody keeps a copy of what was read of a response body and hands it over when closed.
+type recordingBody struct {
+	io.ReadCloser
+	buf  bytes.Buffer
+	done func(body []byte)
+}
+
+func (b *recordingBody) Read(p []byte) (int, error) {
+	n, err := b.ReadCloser.Read(p)
+	b.buf.Write(p[:n])
+	return n, err
+}
+
+func (b *recordingBody) Close() error {
+	if b.done != nil {
+		b.done(b.buf.Bytes())
+		b.done = nil
+	}
+	return b.ReadCloser.Close()
+}
@@ -0,0 +1,157 @@
+package crawler
+
+import (
+	"flag"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+var record = flag.Bool("record", false, "record the cassettes in testdata again from the fixture sites")
+
+// fixturePages are the pages of the sites the crawl tests record their cassettes from, by host and path.
+var fixturePages = map[string]string{
+	"blog.test/blog/": `<h1>Blog</h1><a href="/blog/first">First post</a><a href="/blog/second">Second post</a>
+		<a href="/blog/third">Third post</a><a href="/about">About</a>`,
+	"blog.test/blog/first": `<p>first</p><a href="/blog/">Blog</a><a href="/blog/second">next</a>`,
+	"blog.test/about":      `<p>about</p><a href="/blog/">Blog</a>`,
+	"news.test/hub/earthquakes": `<h1>Earthquakes</h1><a href="/article/quake-coast">Quake hits coast</a>
+		<a href="/article/aftershocks">Aftershocks expected</a>`,
+	"news.test/article/aftershocks": `<p>aftershocks</p><a href="/hub/earthquakes">Earthquakes</a>`,
+	"world.test/":                   `<h1>World</h1><a href="/news/europe">Europe</a><a href="/news/asia">Asia</a>`,
+	"frontpage.test/": `<h1>Front page</h1>
+		<a href="/world"><img src="/img/world.jpg"></a><a href="/politics"><img src="/img/politics.png"></a>
+		<a href="/business"><img src="/img/business.webp"></a><a href="/sport"><img src="/img/sport.jpg"></a>
+		<img src="https://media.frontpage.test/img/weather.gif"><img src="/img/logo.svg">`,
+}
+
+// fixtureSites serves fixturePages to a crawler using it as its proxy, so cassettes are
+// recorded for the sites' own urls.
+func fixtureSites() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		page, ok := fixturePages[r.URL.Host+r.URL.Path]
+		if !ok {
+			http.NotFound(w, r)
+			return
+		}
+		w.Header().Set("Content-Type", "text/html; charset=utf-8")
+		_, _ = w.Write([]byte(`<html><body>` + page + `</body></html>`))
+	})
+}
+
+// useCassette replays a cassette in testdata, or records it from the fixture sites with
+// -record. A cassette that is missing fails the test.
+func useCassette(t *testing.T, c *Crawler, name string) {
+	t.Helper()
+	path := filepath.Join("testdata", name)
+	if *record {
+		assert.NoError(t, os.MkdirAll("testdata", 0o755))
+		server := httptest.NewServer(fixtureSites())
+		t.Cleanup(server.Close)
+		c.Proxies = []string{server.URL}
+		c.Record = path
+		return
+	}
+	if _, err := os.Stat(path); err != nil {
+		t.Fatalf("%s is missing, run go test ./crawler -record to record it", path)
+	}
+	c.Replay = path
+}
+
+func TestCassette(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/":
+			w.Header().Set("Content-Type", "text/html")
+			_, _ = w.Write([]byte(`<a href="/moved">moved</a><a href="/logo.png">logo</a><a href="/big">big</a>`))
+		case "/moved":
+			http.Redirect(w, r, "/page", http.StatusMovedPermanently)
+		case "/logo.png":
+			w.Header().Set("Content-Type", "text/html")
+			_, _ = w.Write([]byte{0x89, 'P', 'N', 'G', 0xff, 0xfe})
+		case "/big":
+			w.Header().Set("Content-Type", "text/html")
+			_, _ = w.Write([]byte(strings.Repeat("x", 4096)))
+		default:
+			w.Header().Set("Content-Type", "text/html")
+			_, _ = w.Write([]byte(`<a href="/">home</a>`))
+		}
+	}))
+	cassette := filepath.Join(t.TempDir(), "cassette.json")
+
+	crawl := func(set func(c *Crawler)) (map[string]string, []PageResult) {
+		c := NewCrawler()
+		c.Silent = true
+		c.MaxBodySize = 1000
+		set(c)
+		pages, err := c.Crawl(server.URL + "/")
+		assert.NoError(t, err)
+		return pages, c.Results()
+	}
+	recordedPages, recordedResults := crawl(func(c *Crawler) { c.Record = cassette })
+	server.Close()
+	interactions, err := loadCassette(cassette)
+	assert.NoError(t, err)
+	for _, i := range interactions {
+		// responses keep every header the server sent
+		assert.NotEmpty(t, i.Response.Header.Get("Date"), i.Request.URL)
+		assert.NotEmpty(t, i.Response.Header.Get("Content-Type"), i.Request.URL)
+	}
+
+	pages, results := crawl(func(c *Crawler) { c.Replay = cassette })
+	assert.Equal(t, recordedPages, pages)
+	assert.Equal(t, recordedResults, results)
+	assert.Equal(t, "body is larger than 1000 bytes", results[1].Skipped)
+
+	// requests missing from the cassette fail without touching the network
+	c := NewCrawler()
+	c.Silent = true
+	c.Replay = cassette
+	_, err = c.Crawl(server.URL + "/missing")
+	assert.NoError(t, err)
+	assert.Contains(t, c.Results()[0].Error, "no recorded response for GET "+server.URL+"/missing")
+
+	c = NewCrawler()
+	c.Replay = filepath.Join(t.TempDir(), "none.json")
+	_, err = c.Crawl(server.URL + "/")
+	assert.ErrorContains(t, err, "failed to load cassette")
+}
+
+func TestCassetteAddsMissing(t *testing.T) {
+	requests := 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests++
+		w.Header().Set("Content-Type", "text/html")
+		_, _ = w.Write([]byte(`<a href="/next">next</a>`))
+	}))
+	defer server.Close()
+	cassette := filepath.Join(t.TempDir(), "cassette.json")
+
+	crawl := func(maxDepth int) {
+		c := NewCrawler()
+		c.Silent = true
+		c.MaxDepth = maxDepth
+		c.Record = cassette
+		c.Replay = cassette
+		if maxDepth == 1 {
+			c.Replay = "" // nothing to replay yet
+		}
+		_, err := c.Crawl(server.URL + "/")
+		assert.NoError(t, err)
+	}
+	crawl(1)
+	assert.Equal(t, 1, requests)
+	crawl(2)
+	assert.Equal(t, 2, requests) // only the page missing from the cassette was fetched
+	crawl(2)
+	assert.Equal(t, 2, requests)
+
+	interactions, err := loadCassette(cassette)
+	assert.NoError(t, err)
+	assert.Len(t, interactions, 2)
+}
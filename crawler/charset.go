@@ -0,0 +1,30 @@
+package crawler
+
+import (
+	"fmt"
+	"strings"
+	"unicode/utf8"
+
+	"golang.org/x/net/html/charset"
+	"golang.org/x/text/encoding"
+)
+
+// decodeHTML converts a page to UTF-8. The charset comes from a byte order mark, the
+// Content-Type header or a <meta charset> or http-equiv tag, in that order, and is
+// sniffed from the content otherwise. It returns the page and the charset name.
+func decodeHTML(body []byte, contentType string) (string, string, error) {
+	e, name, certain := charset.DetermineEncoding(body, contentType)
+	// without any declaration DetermineEncoding only looks at the first 1024 bytes
+	// before falling back to windows-1252, most undeclared pages are UTF-8
+	if !certain && name == "windows-1252" && utf8.Valid(body) {
+		e, name = encoding.Nop, "utf-8"
+	}
+	if e == encoding.Nop {
+		return string(body), name, nil
+	}
+	decoded, err := e.NewDecoder().Bytes(body)
+	if err != nil {
+		return "", name, fmt.Errorf("failed to decode %s: %w", name, err)
+	}
+	return strings.TrimPrefix(string(decoded), "\ufeff"), name, nil
+}
@@ -0,0 +1,85 @@
+package crawler
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"golang.org/x/text/encoding"
+	"golang.org/x/text/encoding/charmap"
+	"golang.org/x/text/encoding/japanese"
+	"golang.org/x/text/encoding/simplifiedchinese"
+	"golang.org/x/text/encoding/unicode"
+)
+
+func encode(t *testing.T, e encoding.Encoding, s string) []byte {
+	t.Helper()
+	data, err := e.NewEncoder().Bytes([]byte(s))
+	assert.NoError(t, err)
+	return data
+}
+
+func TestDecodeHTML(t *testing.T) {
+	tests := []struct {
+		name        string
+		body        []byte
+		contentType string
+		want        string
+		wantCharset string
+	}{
+		{"shift_jis header", encode(t, japanese.ShiftJIS, "<p>日本語のページ</p>"), "text/html; charset=Shift_JIS",
+			"<p>日本語のページ</p>", "shift_jis"},
+		{"gbk header", encode(t, simplifiedchinese.GBK, "<p>中文网页</p>"), "text/html; charset=GBK",
+			"<p>中文网页</p>", "gbk"},
+		{"windows-1251 meta charset", encode(t, charmap.Windows1251, `<meta charset="windows-1251"><p>Привет</p>`), "text/html",
+			`<meta charset="windows-1251"><p>Привет</p>`, "windows-1251"},
+		{"iso-8859-1 http-equiv", encode(t, charmap.ISO8859_1, `<meta http-equiv="Content-Type" content="text/html; charset=iso-8859-1"><p>Café</p>`), "",
+			`<meta http-equiv="Content-Type" content="text/html; charset=iso-8859-1"><p>Café</p>`, "windows-1252"},
+		{"header wins over meta", encode(t, charmap.Windows1251, `<meta charset="utf-8"><p>Привет</p>`), "text/html; charset=windows-1251",
+			`<meta charset="utf-8"><p>Привет</p>`, "windows-1251"},
+		{"utf-16 bom", encode(t, unicode.UTF16(unicode.LittleEndian, unicode.UseBOM), "<p>Grüße</p>"), "text/html; charset=iso-8859-1",
+			"<p>Grüße</p>", "utf-16le"},
+		{"undeclared utf-8", []byte("<p>" + string(make([]byte, 2000)) + "naïve</p>"), "text/html",
+			"<p>" + string(make([]byte, 2000)) + "naïve</p>", "utf-8"},
+		{"undeclared legacy", encode(t, charmap.Windows1252, "<p>naïve</p>"), "",
+			"<p>naïve</p>", "windows-1252"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, name, err := decodeHTML(tt.body, tt.contentType)
+			assert.NoError(t, err)
+			assert.Equal(t, tt.want, got)
+			assert.Equal(t, tt.wantCharset, name)
+		})
+	}
+}
+
+func TestCrawlLegacyCharset(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/html; charset=Shift_JIS")
+		_, _ = w.Write(encode(t, japanese.ShiftJIS, `<div class="news"><a href="/地震">地震情報</a></div>`))
+	}))
+	defer server.Close()
+
+	c := NewCrawler()
+	c.Silent = true
+	c.MaxDepth = 1
+	c.RecordGraph = true
+	c.SearchAny = []string{"地震情報"}
+	_, err := c.Crawl(server.URL + "/")
+	assert.NoError(t, err)
+	assert.Equal(t, []string{server.URL + "/"}, c.collectedItems)
+	assert.Equal(t, "shift_jis", c.Results()[0].Charset)
+	edges := c.Graph().Edges
+	assert.Len(t, edges, 1)
+	assert.Equal(t, "地震情報", edges[0].Text)
+}
+
+func TestLegacyFeed(t *testing.T) {
+	body := encode(t, charmap.ISO8859_1, `<?xml version="1.0" encoding="ISO-8859-1"?>
+<rss version="2.0"><channel><item><title>Café news</title><link>https://example.com/cafe</link></item></channel></rss>`)
+	entries, ok := parseFeed("https://example.com/feed", body)
+	assert.True(t, ok)
+	assert.Equal(t, []FeedEntry{{URL: "https://example.com/cafe", Title: "Café news", Feed: "https://example.com/feed"}}, entries)
+}
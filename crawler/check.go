@@ -0,0 +1,198 @@
+package crawler
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"slices"
+	"strings"
+	"sync"
+
+	"github.com/gtsteffaniak/html-web-crawler/browser"
+)
+
+// LinkStatus is a link found by Check that could not be loaded.
+type LinkStatus struct {
+	URL        string       `json:"url"`
+	StatusCode int          `json:"status_code,omitempty"` // 0 when no response was received
+	Error      string       `json:"error,omitempty"`
+	Sources    []LinkSource `json:"sources"`
+}
+
+// LinkSource is a page linking to a checked URL and the anchor text it uses.
+type LinkSource struct {
+	Page string `json:"page"`
+	Text string `json:"text,omitempty"`
+}
+
+// linkCheck collects every link found while checking a site.
+type linkCheck struct {
+	hosts   map[string]bool         // hosts of the seeds, the only ones crawled
+	sources map[string][]LinkSource // pages linking to each url
+}
+
+// Check crawls the internal pages of the given sites and requests every link found on
+// them, internal or external, without following external links. It returns the links
+// that fail or answer with an error status, sorted by URL.
+func (c *Crawler) Check(pageURL ...string) ([]LinkStatus, error) {
+	c.mode = "check"
+	c.errors = []error{}
+	c.linkCheck = &linkCheck{hosts: make(map[string]bool), sources: make(map[string][]LinkSource)}
+	for _, seed := range pageURL {
+		c.linkCheck.hosts[getDomain(c.normalize(seed))] = true
+	}
+	err := c.run(pageURL, "checking", c.checkPage)
+	c.closeBrowser()
+	if err != nil {
+		return nil, err
+	}
+	if len(c.errors) > 0 {
+		return nil, c.errors[0]
+	}
+	return c.checkLinks(), nil
+}
+
+// checkPage fetches an internal page, records the links on it and returns the internal ones to follow.
+func (c *Crawler) checkPage(entry Entry) (map[string]string, error) {
+	pageURL := entry.URL
+	c.mutex.Lock()
+	c.pagesContent[pageURL] = ""
+	c.mutex.Unlock()
+	htmlContent, err := c.FetchHTML(pageURL, c.JsDepth >= entry.Depth)
+	if reason := skipReason(err); reason != "" {
+		c.skip(pageURL, entry.Depth, reason)
+		return nil, nil
+	}
+	if err != nil {
+		if errors.Is(err, browser.ErrNotFound) {
+			return nil, err
+		}
+		c.recordPage(pageURL, func(r *PageResult) {
+			r.Depth = entry.Depth
+			r.Error = err.Error()
+		})
+		return nil, nil
+	}
+	c.recordPage(pageURL, func(r *PageResult) {
+		r.Depth = entry.Depth
+	})
+	// every link is checked, nofollow only keeps a link from being crawled
+	anchors, ok, err := c.pageAnchors(pageURL, htmlContent)
+	if err != nil {
+		if !c.Silent {
+			fmt.Printf("Warning: failed to extract links from %s: %v\n", pageURL, err)
+		}
+		return nil, nil
+	}
+	if !ok {
+		return nil, nil
+	}
+	followed := linkMap(c.followed(pageURL, anchors))
+	internal := make(map[string]string)
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	for link, text := range linkMap(anchors) {
+		fullURL := c.normalize(toAbsoluteURL(pageURL, link))
+		if !strings.HasPrefix(fullURL, "http://") && !strings.HasPrefix(fullURL, "https://") {
+			continue // mailto:, tel:, javascript: and the like
+		}
+		source := LinkSource{Page: pageURL, Text: text}
+		if !slices.Contains(c.linkCheck.sources[fullURL], source) {
+			c.linkCheck.sources[fullURL] = append(c.linkCheck.sources[fullURL], source)
+		}
+		if _, follow := followed[link]; follow && c.linkCheck.hosts[getDomain(fullURL)] {
+			internal[link] = text
+		}
+	}
+	return internal, nil
+}
+
+// checkLinks requests every link that wasn't crawled and returns the broken ones.
+func (c *Crawler) checkLinks() []LinkStatus {
+	excluded := make(map[string]bool)
+	for _, url := range c.Selectors.ExcludedUrls {
+		excluded[c.normalize(url)] = true
+	}
+	broken := []LinkStatus{}
+	unchecked := []string{}
+	c.mutex.Lock()
+	// crawled pages, including seeds nothing links to, were already requested
+	for url, r := range c.pageResults {
+		if r.Error != "" && !excluded[url] {
+			sources := append([]LinkSource{}, c.linkCheck.sources[url]...)
+			broken = append(broken, LinkStatus{URL: url, StatusCode: r.StatusCode, Error: r.Error, Sources: sources})
+		}
+	}
+	for url := range c.linkCheck.sources {
+		if _, crawled := c.pageResults[url]; !crawled && !excluded[url] {
+			unchecked = append(unchecked, url)
+		}
+	}
+	c.mutex.Unlock()
+
+	urls := make(chan string)
+	var wg sync.WaitGroup
+	for range max(c.Threads, 1) {
+		wg.Go(func() {
+			for url := range urls {
+				status, err := c.checkLink(url)
+				if err == nil && status < 400 {
+					continue
+				}
+				result := LinkStatus{URL: url, StatusCode: status}
+				if err != nil {
+					result.Error = err.Error()
+				} else {
+					result.Error = http.StatusText(status)
+				}
+				c.mutex.Lock()
+				result.Sources = c.linkCheck.sources[url]
+				broken = append(broken, result)
+				c.mutex.Unlock()
+			}
+		})
+	}
+	for _, url := range unchecked {
+		urls <- url
+	}
+	close(urls)
+	wg.Wait()
+
+	for i := range broken {
+		slices.SortFunc(broken[i].Sources, func(a, b LinkSource) int {
+			return strings.Compare(a.Page, b.Page)
+		})
+	}
+	slices.SortFunc(broken, func(a, b LinkStatus) int {
+		return strings.Compare(a.URL, b.URL)
+	})
+	return broken
+}
+
+// checkLink requests a link with HEAD, falling back to GET for servers that don't answer HEAD properly.
+func (c *Crawler) checkLink(linkURL string) (int, error) {
+	if !c.Silent {
+		fmt.Println("checking", linkURL)
+	}
+	status, err := c.requestStatus(http.MethodHead, linkURL)
+	if err == nil && status < 400 {
+		return status, nil
+	}
+	return c.requestStatus(http.MethodGet, linkURL)
+}
+
+// requestStatus returns the status code of a request, discarding the body.
+func (c *Crawler) requestStatus(method, linkURL string) (int, error) {
+	req, err := http.NewRequest(method, linkURL, nil)
+	if err != nil {
+		return 0, err
+	}
+	resp, err := c.do(req)
+	if err != nil {
+		return 0, err
+	}
+	_, _ = io.Copy(io.Discard, io.LimitReader(resp.Body, 64*1024))
+	_ = resp.Body.Close()
+	return resp.StatusCode, nil
+}
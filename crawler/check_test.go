@@ -0,0 +1,110 @@
+package crawler
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"strings"
+	"sync/atomic"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCheck(t *testing.T) {
+	var externalGets atomic.Int32
+	external := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method == http.MethodGet {
+			externalGets.Add(1)
+		}
+		switch r.URL.Path {
+		case "/ok":
+			_, _ = w.Write([]byte(`<a href="/deep-broken">never checked</a>`))
+		case "/no-head":
+			if r.Method == http.MethodHead {
+				w.WriteHeader(http.StatusMethodNotAllowed)
+				return
+			}
+			_, _ = w.Write([]byte(`ok`))
+		default:
+			w.WriteHeader(http.StatusInternalServerError)
+		}
+	}))
+	defer external.Close()
+	var server *httptest.Server
+	server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		site := func(s string) string { return strings.ReplaceAll(s, "EXT", external.URL) }
+		switch r.URL.Path {
+		case "/":
+			_, _ = w.Write([]byte(site(`<a href="/guide">Guide</a><a href="/missing">Old page</a>
+				<a href="EXT/ok">partner</a><a href="mailto:docs@example.com">mail</a>`)))
+		case "/guide":
+			_, _ = w.Write([]byte(site(`<a href="/missing#section">see also</a><a href="EXT/no-head">api</a>
+				<a href="EXT/down">status page</a><a href="/">home</a>`)))
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer server.Close()
+
+	c := NewCrawler()
+	c.Silent = true
+	c.MaxDepth = 5
+	c.Threads = 3
+	broken, err := c.Check(server.URL)
+	assert.NoError(t, err)
+	want := []LinkStatus{
+		{
+			URL:        external.URL + "/down",
+			StatusCode: http.StatusInternalServerError,
+			Error:      "Internal Server Error",
+			Sources:    []LinkSource{{Page: server.URL + "/guide", Text: "status page"}},
+		},
+		{
+			URL:        server.URL + "/missing",
+			StatusCode: http.StatusNotFound,
+			Error:      "HTTP 404 404 Not Found for " + server.URL + "/missing",
+			Sources: []LinkSource{
+				{Page: server.URL + "/", Text: "Old page"},
+				{Page: server.URL + "/guide", Text: "see also"},
+			},
+		},
+	}
+	slices.SortFunc(want, func(a, b LinkStatus) int { return strings.Compare(a.URL, b.URL) })
+	assert.Equal(t, want, broken)
+	assert.Equal(t, int32(2), externalGets.Load(), "external links are checked with HEAD first and never crawled")
+}
+
+func TestCheckNofollowLinks(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/html")
+		switch r.URL.Path {
+		case "/":
+			_, _ = w.Write([]byte(`<a rel="nofollow" href="/gone">Gone</a><a rel="ugc" href="/forum">Forum</a>
+				<a href="/private">Private</a>`))
+		case "/forum":
+			_, _ = w.Write([]byte(`<a href="/forum-broken">never checked</a>`))
+		case "/private":
+			_, _ = w.Write([]byte(`<meta name="robots" content="nofollow"><a href="/private-broken">Draft</a>`))
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer server.Close()
+
+	c := NewCrawler()
+	c.Silent = true
+	c.MaxDepth = 5
+	c.RobotsDirectives = true
+	broken, err := c.Check(server.URL)
+	assert.NoError(t, err)
+	// nofollow links are checked, they are only left out of the crawl
+	urls := []string{}
+	for _, link := range broken {
+		urls = append(urls, link.URL)
+	}
+	assert.Equal(t, []string{server.URL + "/gone", server.URL + "/private-broken"}, urls)
+	if assert.Len(t, broken, 2) {
+		assert.Equal(t, []LinkSource{{Page: server.URL + "/private", Text: "Draft"}}, broken[1].Sources)
+	}
+}
@@ -1,11 +1,13 @@
 package crawler
 
 import (
+	"errors"
 	"fmt"
 	"regexp"
 	"slices"
 	"strings"
-	"sync"
+
+	"github.com/gtsteffaniak/html-web-crawler/browser"
 )
 
 var collectionTypes = map[string]string{
@@ -25,37 +27,18 @@ var collectionTypes = map[string]string{
 	"font":    `([https?:]|\/)[^\s()'"]+\.(?:ttf|otf|woff|woff2|eot|svg)`,
 }
 
-// Crawl is the public method that initializes the recursive crawling.
+// Collect is the public method that initializes the collection crawl.
 func (c *Crawler) Collect(pageURL ...string) ([]string, error) {
 	c.mode = "collect"
 	if err := c.compileCollections(); err != nil {
 		return nil, fmt.Errorf("failed to compile collection patterns: %w", err)
 	}
-	c.wg = sync.WaitGroup{}
 	c.errors = []error{} // Initialize errors slice
-	// Initialize shared semaphore for concurrency control
-	if c.Threads > 0 {
-		c.semaphore = make(chan struct{}, c.Threads)
-	} else {
-		c.semaphore = make(chan struct{}, 1) // Default to 1 if not set
-	}
-	for _, url := range c.Selectors.ExcludedUrls {
-		c.pagesContent[url] = ""
-	}
-	for _, url := range pageURL {
-		c.wg.Go(func() {
-			err := c.recursiveCollect(url, 1)
-			if err != nil {
-				c.mutex.Lock()
-				c.errors = append(c.errors, err)
-				c.mutex.Unlock()
-				if !c.Silent {
-					fmt.Printf("Error crawling %s: %v\n", url, err)
-				}
-			}
-		})
+	err := c.run(pageURL, "collecting", c.collectPage)
+	c.closeBrowser()
+	if err != nil {
+		return nil, err
 	}
-	c.wg.Wait() // Wait for all goroutines to finish
 	slices.Sort(c.collectedItems)
 	// Return the first error if any occurred
 	if len(c.errors) > 0 {
@@ -79,35 +62,72 @@ func (c *Crawler) compileCollections() error {
 	return nil
 }
 
-// recursiveCrawl is a private method that performs the recursive crawling, respecting MaxDepth.
-func (c *Crawler) recursiveCollect(pageURL string, currentDepth int) error {
-	useJavascript := c.JsDepth >= currentDepth
-	if currentDepth > c.MaxDepth {
-		return nil
-	}
-	// Use single lock to check and set atomically to prevent race conditions
-	c.mutex.Lock()
-	if _, ok := c.pagesContent[pageURL]; ok {
-		c.mutex.Unlock()
-		return nil
+// collectBlocked collects requests the browser skipped that match the collection patterns,
+// so blocking images or media while rendering does not hide them from Collect.
+func (c *Crawler) collectBlocked(pageURL string, urls []string) {
+	items := []string{}
+	for _, u := range urls {
+		for _, re := range c.regexPatterns {
+			if re.MatchString(u) && c.validDomainCheck(u) {
+				items = append(items, u)
+				break
+			}
+		}
 	}
-	if c.MaxLinks > 0 && len(c.pagesContent) >= c.MaxLinks {
-		c.mutex.Unlock()
-		return nil
+	c.addItems(pageURL, items)
+}
+
+// addItems stores items collected from a page on its result and in the collection.
+func (c *Crawler) addItems(pageURL string, items []string) {
+	if len(items) == 0 {
+		return
 	}
-	// Mark as processing before releasing lock
+	c.recordPage(pageURL, func(r *PageResult) {
+		r.Items = append(r.Items, items...)
+	})
+	c.mutex.Lock()
+	c.collectedItems = append(c.collectedItems, items...)
+	c.mutex.Unlock()
+}
+
+// collectPage fetches a page, collects the items on it and returns the links to follow.
+func (c *Crawler) collectPage(entry Entry) (map[string]string, error) {
+	pageURL := entry.URL
+	useJavascript := c.JsDepth >= entry.Depth
+	c.mutex.Lock()
 	c.pagesContent[pageURL] = ""
 	c.mutex.Unlock()
 	htmlContent, err := c.FetchHTML(pageURL, useJavascript)
+	if errors.Is(err, errNotModified) {
+		c.recordPage(pageURL, func(r *PageResult) {
+			r.Depth = entry.Depth
+		})
+		return c.notModified(pageURL), nil
+	}
+	if reason := skipReason(err); reason != "" {
+		c.skip(pageURL, entry.Depth, reason)
+		return nil, nil
+	}
 	if err != nil {
+		if errors.Is(err, browser.ErrNotFound) {
+			// Without a browser no javascript page can be fetched, stop the crawl
+			return nil, err
+		}
 		// Log transient HTTP errors but don't fail the entire crawl
 		// These are expected when scraping (403, 404, network issues, etc.)
 		if !c.Silent {
 			fmt.Printf("Warning: failed to fetch %s: %v\n", pageURL, err)
 		}
-		return nil // Continue crawling other pages
+		c.recordPage(pageURL, func(r *PageResult) {
+			r.Depth = entry.Depth
+			r.Error = err.Error()
+		})
+		return nil, nil // Continue crawling other pages
 	}
-	if currentDepth > 0 && len(c.Selectors.ContentPatterns) > 0 {
+	c.recordPage(pageURL, func(r *PageResult) {
+		r.Depth = entry.Depth
+	})
+	if len(c.Selectors.ContentPatterns) > 0 {
 		matchContentPattern := false
 		for _, pattern := range c.Selectors.ContentPatterns {
 			if strings.Contains(htmlContent, pattern) {
@@ -115,69 +135,36 @@ func (c *Crawler) recursiveCollect(pageURL string, currentDepth int) error {
 			}
 		}
 		if !matchContentPattern {
-			return nil
+			return nil, nil
 		}
 	}
-	links, err := c.extractLinks(htmlContent)
+	links, err := c.pageLinks(pageURL, htmlContent)
 	if err != nil {
 		// HTML parsing errors are common with malformed HTML - log but continue
 		if !c.Silent {
 			fmt.Printf("Warning: failed to extract links from %s: %v\n", pageURL, err)
 		}
-		return nil // Continue with other pages
+		return nil, nil // Continue with other pages
 	}
-	items, err := c.extractItems(htmlContent, pageURL)
+	c.rememberLinks(pageURL, links)
+	if c.noindex(pageURL) {
+		return links, nil // nothing is collected from pages that asked not to be indexed
+	}
+	items, err := c.extractItems(htmlContent, c.finalURL(pageURL))
 	if err != nil {
 		// HTML parsing errors are common - log but continue
 		if !c.Silent {
 			fmt.Printf("Warning: failed to extract items from %s: %v\n", pageURL, err)
 		}
-		return nil // Continue with other pages
+		return nil, nil // Continue with other pages
 	}
-	// Batch mutex operations for better performance
-	c.mutex.Lock()
-	c.collectedItems = append(c.collectedItems, items...)
 	// If "html" is in Collections, also collect the page URL itself
 	if slices.Contains(c.Selectors.Collections, "html") {
-		c.collectedItems = append(c.collectedItems, pageURL)
+		items = append(items, pageURL)
 	}
-	c.mutex.Unlock()
-
-	// Process links with shared semaphore for concurrency control
-	for link, linkText := range links {
-		// Check if already processed (with lock)
-		c.mutex.Lock()
-		_, alreadyProcessed := c.pagesContent[link]
-		c.mutex.Unlock()
-		if alreadyProcessed {
-			continue
-		}
-		if !c.linkTextCheck(link, linkText) {
-			continue
-		}
-
-		fullURL := toAbsoluteURL(pageURL, link)
-		if !c.validDomainCheck(fullURL) {
-			continue
-		}
-
-		// Start goroutine, acquire semaphore inside to prevent deadlock
-		c.wg.Go(func() {
-			// Acquire semaphore slot (may block if all slots are taken)
-			c.semaphore <- struct{}{}
-			defer func() {
-				<-c.semaphore // Release the slot
-			}()
-			err := c.recursiveCollect(fullURL, currentDepth+1)
-			if err != nil {
-				c.mutex.Lock()
-				c.errors = append(c.errors, err)
-				c.mutex.Unlock()
-				if !c.Silent {
-					fmt.Printf("Error collecting %s: %v\n", fullURL, err)
-				}
-			}
-		})
+	if c.OnlyChanged && c.unchanged(pageURL) {
+		return links, nil
 	}
-	return nil
+	c.addItems(pageURL, items)
+	return links, nil
 }
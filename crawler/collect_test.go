@@ -112,6 +112,21 @@ func TestExtractItems(t *testing.T) {
 	}
 }
 
+func TestCollectBlocked(t *testing.T) {
+	c := NewCrawler()
+	c.Selectors.Collections = []string{"images"}
+	c.Selectors.ExcludeDomains = []string{"ads.com"}
+	err := c.compileCollections()
+	assert.NoError(t, err)
+	c.collectBlocked("https://cdn.example.com/", []string{
+		"https://cdn.example.com/hero.jpg",
+		"https://cdn.example.com/app.js",
+		"https://ads.com/banner.png",
+	})
+	assert.Equal(t, []string{"https://cdn.example.com/hero.jpg"}, c.collectedItems)
+	assert.Equal(t, []string{"https://cdn.example.com/hero.jpg"}, c.pageResults["https://cdn.example.com/"].Items)
+}
+
 func Benchmark_collectionSearch(b *testing.B) {
 	// Pick a representative test case from tests
 	testHtml := `
@@ -139,7 +154,8 @@ func TestSingleSourceRunCollectHtml(t *testing.T) {
 	c.Threads = 1
 	c.MaxDepth = 1
 	c.MaxLinks = 3
-	results, err := c.Collect("https://www.cnn.com/")
+	useCassette(t, c, "frontpage_html.json")
+	results, err := c.Collect("http://frontpage.test/")
 	assert.Equal(t, nil, err)
 	// With MaxLinks=3 and MaxDepth=1, we should get at least the starting URL plus some links
 	assert.GreaterOrEqual(t, len(results), 1, "Should collect at least the starting page URL")
@@ -152,7 +168,8 @@ func TestSingleSourceRunCollectImages(t *testing.T) {
 	c.Threads = 10
 	c.MaxLinks = 3
 	c.Selectors.Collections = []string{"images"}
-	results, err := c.Collect("https://www.cnn.com/")
+	useCassette(t, c, "frontpage_images.json")
+	results, err := c.Collect("http://frontpage.test/")
 	assert.Equal(t, nil, err)
 	assert.GreaterOrEqual(t, len(results), 5)
 }
@@ -0,0 +1,281 @@
+package crawler
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"maps"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"path"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"golang.org/x/net/publicsuffix"
+)
+
+// httpOnlyPrefix marks HttpOnly cookies in Netscape cookies.txt files.
+const httpOnlyPrefix = "#HttpOnly_"
+
+// CookieJar is an http.CookieJar that can be listed, and loaded from and saved to
+// Netscape cookies.txt files, so sessions can be shared with browsers and other tools.
+type CookieJar struct {
+	mutex   sync.Mutex
+	cookies map[string]jarCookie // by domain, path and name
+}
+
+type jarCookie struct {
+	http.Cookie
+	hostOnly bool // only sent to Domain itself, not its subdomains
+}
+
+// NewCookieJar returns an empty cookie jar.
+func NewCookieJar() *CookieJar {
+	return &CookieJar{cookies: make(map[string]jarCookie)}
+}
+
+// SetCookies stores the cookies received from u, removing the ones that expired.
+func (j *CookieJar) SetCookies(u *url.URL, cookies []*http.Cookie) {
+	host := strings.ToLower(u.Hostname())
+	now := time.Now()
+	j.mutex.Lock()
+	defer j.mutex.Unlock()
+	for _, cookie := range cookies {
+		c := jarCookie{Cookie: *cookie}
+		var ok bool
+		c.Domain, c.hostOnly, ok = cookieDomain(host, cookie.Domain)
+		if !ok {
+			continue // a site can't set cookies for other sites
+		}
+		if c.Path == "" || !strings.HasPrefix(c.Path, "/") {
+			c.Path = defaultCookiePath(u.Path)
+		}
+		switch {
+		case cookie.MaxAge < 0:
+			c.Expires = now.Add(-time.Second)
+		case cookie.MaxAge > 0:
+			c.Expires = now.Add(time.Duration(cookie.MaxAge) * time.Second)
+		}
+		c.MaxAge = 0
+		c.Raw = ""
+		key := cookieKey(c)
+		if !c.Expires.IsZero() && !c.Expires.After(now) {
+			delete(j.cookies, key)
+			continue
+		}
+		j.cookies[key] = c
+	}
+}
+
+// Cookies returns the cookies to send in a request to u.
+func (j *CookieJar) Cookies(u *url.URL) []*http.Cookie {
+	host := strings.ToLower(u.Hostname())
+	requestPath := u.Path
+	if requestPath == "" {
+		requestPath = "/"
+	}
+	secure := u.Scheme == "https"
+	now := time.Now()
+	j.mutex.Lock()
+	matches := []jarCookie{}
+	for key, c := range j.cookies {
+		if !c.Expires.IsZero() && !c.Expires.After(now) {
+			delete(j.cookies, key)
+			continue
+		}
+		if c.hostOnly && host != c.Domain || !c.hostOnly && !domainMatch(host, c.Domain) {
+			continue
+		}
+		if !pathMatch(requestPath, c.Path) || c.Secure && !secure {
+			continue
+		}
+		matches = append(matches, c)
+	}
+	j.mutex.Unlock()
+	// more specific paths first
+	slices.SortFunc(matches, func(a, b jarCookie) int {
+		if n := len(b.Path) - len(a.Path); n != 0 {
+			return n
+		}
+		return strings.Compare(a.Name, b.Name)
+	})
+	cookies := make([]*http.Cookie, len(matches))
+	for i, c := range matches {
+		cookies[i] = &http.Cookie{Name: c.Name, Value: c.Value}
+	}
+	return cookies
+}
+
+// All returns every cookie in the jar, with Domain, Path and Expires filled in.
+func (j *CookieJar) All() []*http.Cookie {
+	j.mutex.Lock()
+	defer j.mutex.Unlock()
+	cookies := make([]*http.Cookie, 0, len(j.cookies))
+	for _, key := range slices.Sorted(maps.Keys(j.cookies)) {
+		c := j.cookies[key].Cookie
+		cookies = append(cookies, &c)
+	}
+	return cookies
+}
+
+// Load adds the cookies of a Netscape cookies.txt file to the jar.
+func (j *CookieJar) Load(filename string) error {
+	f, err := os.Open(filename)
+	if err != nil {
+		return fmt.Errorf("failed to load cookies: %w", err)
+	}
+	defer func() {
+		_ = f.Close()
+	}()
+	j.mutex.Lock()
+	defer j.mutex.Unlock()
+	scanner := bufio.NewScanner(f)
+	for line := 1; scanner.Scan(); line++ {
+		text := strings.TrimRight(scanner.Text(), "\r")
+		httpOnly := false
+		if rest, ok := strings.CutPrefix(text, httpOnlyPrefix); ok {
+			text, httpOnly = rest, true
+		}
+		if strings.TrimSpace(text) == "" || strings.HasPrefix(text, "#") {
+			continue
+		}
+		fields := strings.Split(text, "\t")
+		if len(fields) != 7 {
+			return fmt.Errorf("failed to load cookies: %s:%d: expected 7 tab separated fields", filename, line)
+		}
+		expires, err := strconv.ParseInt(fields[4], 10, 64)
+		if err != nil {
+			return fmt.Errorf("failed to load cookies: %s:%d: invalid expiry %q", filename, line, fields[4])
+		}
+		c := jarCookie{Cookie: http.Cookie{
+			Domain:   strings.TrimPrefix(strings.ToLower(fields[0]), "."),
+			Path:     fields[2],
+			Secure:   strings.EqualFold(fields[3], "TRUE"),
+			Name:     fields[5],
+			Value:    fields[6],
+			HttpOnly: httpOnly,
+		}}
+		c.hostOnly = !strings.EqualFold(fields[1], "TRUE")
+		if expires > 0 {
+			c.Expires = time.Unix(expires, 0)
+		}
+		j.cookies[cookieKey(c)] = c
+	}
+	if err := scanner.Err(); err != nil {
+		return fmt.Errorf("failed to load cookies: %w", err)
+	}
+	return nil
+}
+
+// Save writes the jar to a Netscape cookies.txt file, session cookies have an expiry of 0.
+func (j *CookieJar) Save(filename string) error {
+	var sb strings.Builder
+	sb.WriteString("# Netscape HTTP Cookie File\n")
+	now := time.Now()
+	j.mutex.Lock()
+	for _, key := range slices.Sorted(maps.Keys(j.cookies)) {
+		c := j.cookies[key]
+		if !c.Expires.IsZero() && !c.Expires.After(now) {
+			continue
+		}
+		domain, subdomains := c.Domain, "FALSE"
+		if !c.hostOnly {
+			domain, subdomains = "."+c.Domain, "TRUE"
+		}
+		if c.HttpOnly {
+			domain = httpOnlyPrefix + domain
+		}
+		expires := int64(0)
+		if !c.Expires.IsZero() {
+			expires = c.Expires.Unix()
+		}
+		fmt.Fprintf(&sb, "%s\t%s\t%s\t%s\t%d\t%s\t%s\n",
+			domain, subdomains, c.Path, strings.ToUpper(strconv.FormatBool(c.Secure)), expires, c.Name, c.Value)
+	}
+	j.mutex.Unlock()
+	if err := os.WriteFile(filename, []byte(sb.String()), 0o600); err != nil {
+		return fmt.Errorf("failed to save cookies: %w", err)
+	}
+	return nil
+}
+
+// loadCookies reads CookieFile into the jar before a crawl, a missing file starts an empty session.
+func (c *Crawler) loadCookies() error {
+	if c.CookieFile == "" || c.Jar == nil {
+		return nil
+	}
+	err := c.Jar.Load(c.CookieFile)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil
+	}
+	return err
+}
+
+// saveCookies writes the jar back to CookieFile after a crawl.
+func (c *Crawler) saveCookies() error {
+	if c.CookieFile == "" || c.Jar == nil {
+		return nil
+	}
+	return c.Jar.Save(c.CookieFile)
+}
+
+func cookieKey(c jarCookie) string {
+	return c.Domain + ";" + c.Path + ";" + c.Name
+}
+
+// cookieDomain returns the domain a cookie set by host is kept for, and whether it is only
+// sent to host itself. Domain attributes for other sites, for public suffixes such as com or
+// co.uk, and on IP addresses are only accepted when they name host itself (RFC 6265 section 5.3).
+func cookieDomain(host, domain string) (string, bool, bool) {
+	domain = strings.TrimPrefix(strings.ToLower(domain), ".")
+	isIP := net.ParseIP(host) != nil
+	if domain == "" || domain == host && (isIP || isPublicSuffix(host)) {
+		return host, true, true
+	}
+	if isIP || !domainMatch(host, domain) || isPublicSuffix(domain) {
+		return "", false, false
+	}
+	return domain, false, true
+}
+
+// isPublicSuffix reports whether a domain is one under which anyone can register names.
+func isPublicSuffix(domain string) bool {
+	suffix, _ := publicsuffix.PublicSuffix(domain)
+	return suffix == domain
+}
+
+// domainMatch reports whether host is domain or one of its subdomains.
+func domainMatch(host, domain string) bool {
+	return host == domain || strings.HasSuffix(host, "."+domain)
+}
+
+// pathMatch reports whether a cookie for cookiePath is sent with requests for requestPath.
+func pathMatch(requestPath, cookiePath string) bool {
+	if requestPath == cookiePath {
+		return true
+	}
+	if !strings.HasPrefix(requestPath, cookiePath) {
+		return false
+	}
+	return strings.HasSuffix(cookiePath, "/") || requestPath[len(cookiePath)] == '/'
+}
+
+// defaultCookiePath is the directory of the request path, as cookies without a path use.
+func defaultCookiePath(requestPath string) string {
+	if requestPath == "" || !strings.HasPrefix(requestPath, "/") {
+		return "/"
+	}
+	dir := path.Dir(requestPath)
+	if requestPath[len(requestPath)-1] == '/' {
+		dir = strings.TrimSuffix(requestPath, "/")
+	}
+	if dir == "" || dir == "." {
+		return "/"
+	}
+	return dir
+}
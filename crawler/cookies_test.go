@@ -0,0 +1,169 @@
+package crawler
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strconv"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCookieJar(t *testing.T) {
+	jar := NewCookieJar()
+	site, _ := url.Parse("https://www.example.com/blog/post")
+	jar.SetCookies(site, []*http.Cookie{
+		{Name: "host", Value: "1"},
+		{Name: "domain", Value: "2", Domain: ".example.com", Path: "/"},
+		{Name: "secure", Value: "3", Path: "/", Secure: true},
+		{Name: "other", Value: "4", Domain: "other.com"},
+		{Name: "old", Value: "5", Expires: time.Now().Add(-time.Hour)},
+	})
+	sent := func(rawURL string) []string {
+		u, _ := url.Parse(rawURL)
+		got := []string{}
+		for _, c := range jar.Cookies(u) {
+			got = append(got, c.Name+"="+c.Value)
+		}
+		return got
+	}
+
+	tests := []struct {
+		url  string
+		want []string
+	}{
+		{"https://www.example.com/blog/other", []string{"host=1", "domain=2", "secure=3"}},
+		{"http://www.example.com/blog", []string{"host=1", "domain=2"}},
+		{"https://www.example.com/blogs", []string{"domain=2", "secure=3"}},
+		{"https://shop.example.com/blog/x", []string{"domain=2"}},
+		{"https://other.com/", []string{}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.url, func(t *testing.T) {
+			assert.Equal(t, tt.want, sent(tt.url))
+		})
+	}
+
+	jar.SetCookies(site, []*http.Cookie{{Name: "domain", Domain: "example.com", Path: "/", MaxAge: -1}})
+	assert.Empty(t, sent("https://shop.example.com/"))
+}
+
+func TestCookieDomain(t *testing.T) {
+	tests := []struct {
+		host, domain string
+		want         string
+		wantHostOnly bool
+		wantOK       bool
+	}{
+		{"www.example.com", "", "www.example.com", true, true},
+		{"www.example.com", ".Example.com", "example.com", false, true},
+		{"www.example.com", "com", "", false, false},
+		{"www.example.co.uk", "co.uk", "", false, false},
+		{"www.example.co.uk", "example.co.uk", "example.co.uk", false, true},
+		{"foo.github.io", "github.io", "", false, false},
+		{"localhost", "localhost", "localhost", true, true},
+		{"127.0.0.1", "", "127.0.0.1", true, true},
+		{"127.0.0.1", "127.0.0.1", "127.0.0.1", true, true},
+		{"192.168.1.10", "168.1.10", "", false, false},
+		{"::1", "1", "", false, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.host+" "+tt.domain, func(t *testing.T) {
+			domain, hostOnly, ok := cookieDomain(tt.host, tt.domain)
+			assert.Equal(t, tt.wantOK, ok)
+			assert.Equal(t, tt.want, domain)
+			assert.Equal(t, tt.wantHostOnly, hostOnly)
+		})
+	}
+
+	// cookies for a public suffix are dropped, so they are neither sent to other sites nor saved
+	jar := NewCookieJar()
+	site, _ := url.Parse("https://www.example.com/")
+	jar.SetCookies(site, []*http.Cookie{{Name: "tld", Value: "1", Domain: "com"}})
+	ip, _ := url.Parse("http://192.168.1.10/")
+	jar.SetCookies(ip, []*http.Cookie{{Name: "ip", Value: "2", Domain: "168.1.10"}})
+	assert.Empty(t, jar.All())
+	other, _ := url.Parse("https://other.com/")
+	assert.Empty(t, jar.Cookies(other))
+}
+
+func TestCookieFile(t *testing.T) {
+	file := filepath.Join(t.TempDir(), "cookies.txt")
+	expires := time.Now().Add(24 * time.Hour).Truncate(time.Second)
+	err := os.WriteFile(file, []byte("# Netscape HTTP Cookie File\n\n"+
+		".example.com\tTRUE\t/\tFALSE\t0\tsession\tabc\n"+
+		"#HttpOnly_www.example.com\tFALSE\t/account\tTRUE\t"+strconv.FormatInt(expires.Unix(), 10)+"\tlogin\txyz\n"), 0o600)
+	assert.NoError(t, err)
+
+	jar := NewCookieJar()
+	assert.NoError(t, jar.Load(file))
+	assert.Equal(t, []*http.Cookie{
+		{Name: "session", Value: "abc", Domain: "example.com", Path: "/"},
+		{Name: "login", Value: "xyz", Domain: "www.example.com", Path: "/account", Secure: true, HttpOnly: true, Expires: time.Unix(expires.Unix(), 0)},
+	}, jar.All())
+
+	saved := filepath.Join(t.TempDir(), "saved.txt")
+	assert.NoError(t, jar.Save(saved))
+	reloaded := NewCookieJar()
+	assert.NoError(t, reloaded.Load(saved))
+	assert.Equal(t, jar.All(), reloaded.All())
+
+	assert.NoError(t, os.WriteFile(file, []byte("example.com\tTRUE\t/\n"), 0o600))
+	assert.ErrorContains(t, NewCookieJar().Load(file), "expected 7 tab separated fields")
+}
+
+func TestRequestIdentity(t *testing.T) {
+	var mutex sync.Mutex
+	agents := []string{}
+	sessions := []string{}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mutex.Lock()
+		defer mutex.Unlock()
+		if r.Header.Get("X-Token") != "secret" {
+			http.Error(w, "forbidden", http.StatusForbidden)
+			return
+		}
+		agents = append(agents, r.UserAgent())
+		session := ""
+		if cookie, err := r.Cookie("session"); err == nil {
+			session = cookie.Value
+		}
+		sessions = append(sessions, session)
+		switch r.URL.Path {
+		case "/":
+			http.SetCookie(w, &http.Cookie{Name: "visits", Value: "1", Path: "/"})
+			_, _ = w.Write([]byte(`<a href="/next">next</a>`))
+		default:
+			_, _ = w.Write([]byte(`done`))
+		}
+	}))
+	defer server.Close()
+	file := filepath.Join(t.TempDir(), "cookies.txt")
+	u, _ := url.Parse(server.URL)
+	err := os.WriteFile(file, []byte(u.Hostname()+"\tFALSE\t/\tFALSE\t0\tsession\tloaded\n"), 0o600)
+	assert.NoError(t, err)
+
+	c := NewCrawler()
+	c.Silent = true
+	c.UserAgents = []string{"agent-a", "agent-b"}
+	c.Headers = map[string]string{"X-Token": "secret"}
+	c.CookieFile = file
+	results, err := c.Crawl(server.URL)
+	assert.NoError(t, err)
+	assert.Len(t, results, 2)
+	assert.Equal(t, []string{"agent-a", "agent-b"}, agents)
+	assert.Equal(t, []string{"loaded", "loaded"}, sessions)
+
+	saved := NewCookieJar()
+	assert.NoError(t, saved.Load(file))
+	names := []string{}
+	for _, cookie := range saved.All() {
+		names = append(names, cookie.Name)
+	}
+	assert.ElementsMatch(t, []string{"session", "visits"}, names)
+}
@@ -1,86 +1,72 @@
 package crawler
 
 import (
+	"errors"
 	"fmt"
-	"slices"
 	"strings"
-	"sync"
+
+	"github.com/gtsteffaniak/html-web-crawler/browser"
 )
 
-// Crawl is the public method that initializes the recursive crawling.
+// Crawl is the public method that initializes the crawl.
 func (c *Crawler) Crawl(pageURL ...string) (map[string]string, error) {
 	c.mode = "crawl"
-	c.wg = sync.WaitGroup{}
 	c.errors = []error{} // Initialize errors slice
-	// Initialize shared semaphore for concurrency control
-	if c.Threads > 0 {
-		c.semaphore = make(chan struct{}, c.Threads)
-	} else {
-		c.semaphore = make(chan struct{}, 1) // Default to 1 if not set
-	}
-	for _, url := range c.Selectors.ExcludedUrls {
-		c.pagesContent[url] = ""
-	}
-	for _, url := range pageURL {
-		c.wg.Go(func() {
-			err := c.recursiveCrawl(url, 1)
-			if err != nil {
-				c.mutex.Lock()
-				c.errors = append(c.errors, err)
-				c.mutex.Unlock()
-				if !c.Silent {
-					fmt.Printf("Error crawling %s: %v\n", url, err)
-				}
-			}
-		})
-	}
-	c.wg.Wait() // Wait for all goroutines to finish
-
-	for url := range c.pagesContent {
-		if slices.Contains(c.Selectors.ExcludedUrls, url) {
-			delete(c.pagesContent, url)
-		}
+	err := c.run(pageURL, "crawling", c.crawlPage)
+	c.closeBrowser()
+	if err != nil {
+		return c.emitted(), err
 	}
 
 	// Return the first error if any occurred (but still return the results)
 	if len(c.errors) > 0 {
-		return c.pagesContent, c.errors[0]
+		return c.emitted(), c.errors[0]
 	}
-	return c.pagesContent, nil
+	return c.emitted(), nil
 }
 
-// recursiveCrawl is a private method that performs the recursive crawling, respecting MaxDepth.
-func (c *Crawler) recursiveCrawl(pageURL string, currentDepth int) error {
-	if currentDepth > c.MaxDepth {
-		return nil
-	}
-	useJavascript := c.JsDepth >= currentDepth
+// crawlPage fetches a page, stores its content and returns the links to follow.
+func (c *Crawler) crawlPage(entry Entry) (map[string]string, error) {
+	pageURL := entry.URL
+	useJavascript := c.JsDepth >= entry.Depth
 
-	// Use single lock to check and set atomically to prevent race conditions
+	// Mark as processing, failed pages are still listed in the results
 	c.mutex.Lock()
-	if _, ok := c.pagesContent[pageURL]; ok {
-		c.mutex.Unlock()
-		return nil
-	}
-	if c.MaxLinks > 0 && len(c.pagesContent) >= c.MaxLinks {
-		c.mutex.Unlock()
-		return nil
-	}
-	// Mark as processing before releasing lock
 	c.pagesContent[pageURL] = ""
 	c.mutex.Unlock()
 
 	htmlContent, err := c.FetchHTML(pageURL, useJavascript)
+	if errors.Is(err, errNotModified) {
+		c.recordPage(pageURL, func(r *PageResult) {
+			r.Depth = entry.Depth
+		})
+		return c.notModified(pageURL), nil
+	}
+	if reason := skipReason(err); reason != "" {
+		c.skip(pageURL, entry.Depth, reason)
+		return nil, nil
+	}
 	if err != nil {
+		if errors.Is(err, browser.ErrNotFound) {
+			// Without a browser no javascript page can be fetched, stop the crawl
+			return nil, err
+		}
 		// Log transient HTTP errors but don't fail the entire crawl
 		// These are expected when scraping (403, 404, network issues, etc.)
 		if !c.Silent {
 			fmt.Printf("Warning: failed to fetch %s: %v\n", pageURL, err)
 		}
-		return nil // Continue crawling other pages
+		c.recordPage(pageURL, func(r *PageResult) {
+			r.Depth = entry.Depth
+			r.Error = err.Error()
+		})
+		return nil, nil // Continue crawling other pages
 	}
+	c.recordPage(pageURL, func(r *PageResult) {
+		r.Depth = entry.Depth
+	})
 
-	if currentDepth > 0 && len(c.Selectors.ContentPatterns) > 0 {
+	if len(c.Selectors.ContentPatterns) > 0 {
 		matchContentPattern := false
 		for _, pattern := range c.Selectors.ContentPatterns {
 			if strings.Contains(htmlContent, pattern) {
@@ -88,7 +74,7 @@ func (c *Crawler) recursiveCrawl(pageURL string, currentDepth int) error {
 			}
 		}
 		if !matchContentPattern {
-			return nil
+			return nil, nil
 		}
 	}
 
@@ -100,51 +86,14 @@ func (c *Crawler) recursiveCrawl(pageURL string, currentDepth int) error {
 	}
 	c.mutex.Unlock()
 
-	links, err := c.extractLinks(htmlContent)
+	links, err := c.pageLinks(pageURL, htmlContent)
 	if err != nil {
 		// HTML parsing errors are common with malformed HTML - log but continue
 		if !c.Silent {
 			fmt.Printf("Warning: failed to extract links from %s: %v\n", pageURL, err)
 		}
-		return nil // Continue with other pages
-	}
-
-	// Process links with shared semaphore for concurrency control
-	for link, linkText := range links {
-		// Check if already processed (with lock)
-		c.mutex.Lock()
-		_, alreadyProcessed := c.pagesContent[link]
-		c.mutex.Unlock()
-		if alreadyProcessed {
-			continue
-		}
-		if !c.linkTextCheck(link, linkText) {
-			continue
-		}
-
-		fullURL := toAbsoluteURL(pageURL, link)
-		if !c.validDomainCheck(fullURL) {
-			continue
-		}
-
-		// Start goroutine, acquire semaphore inside to prevent deadlock
-		c.wg.Go(func() {
-			// Acquire semaphore slot (may block if all slots are taken)
-			c.semaphore <- struct{}{}
-			defer func() {
-				<-c.semaphore // Release the slot
-			}()
-			err := c.recursiveCrawl(fullURL, currentDepth+1)
-			if err != nil {
-				c.mutex.Lock()
-				c.errors = append(c.errors, err)
-				c.mutex.Unlock()
-				if !c.Silent {
-					fmt.Printf("Error crawling %s: %v\n", fullURL, err)
-				}
-			}
-		})
+		return nil, nil // Continue with other pages
 	}
-
-	return nil
+	c.rememberLinks(pageURL, links)
+	return links, nil
 }
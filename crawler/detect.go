@@ -0,0 +1,140 @@
+package crawler
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+
+	"golang.org/x/net/html"
+)
+
+// minBodyText is the amount of visible text below which a page is considered empty.
+const minBodyText = 200
+
+// appRoots are element ids single page apps commonly mount into.
+var appRoots = []string{"root", "app", "__next", "__nuxt", "svelte", "main-app"}
+
+// fetchAuto fetches a page over HTTP and escalates to the browser when the page looks
+// client rendered. The decision is made on the first page of each host and reused after.
+func (c *Crawler) fetchAuto(pageURL string) (string, error) {
+	host := getDomain(pageURL)
+	c.mutex.Lock()
+	useJavascript, decided := c.jsHosts[host]
+	c.mutex.Unlock()
+	if decided && useJavascript {
+		return c.renderPage(pageURL)
+	}
+	htmlContent, err := c.requestPage(pageURL)
+	if err != nil || decided {
+		return htmlContent, err
+	}
+	reason := c.needsJavascript(htmlContent)
+	c.mutex.Lock()
+	c.jsHosts[host] = reason != ""
+	c.mutex.Unlock()
+	if reason == "" {
+		return htmlContent, nil
+	}
+	if !c.Silent {
+		fmt.Printf("rendering %s with javascript: %s\n", host, reason)
+	}
+	return c.renderPage(pageURL)
+}
+
+// needsJavascript returns why a page fetched over HTTP looks like it needs rendering,
+// or an empty string when the HTML can be used as is.
+func (c *Crawler) needsJavascript(htmlContent string) string {
+	doc, err := html.Parse(strings.NewReader(htmlContent))
+	if err != nil {
+		return ""
+	}
+	if root := emptyAppRoot(doc); root != "" {
+		return fmt.Sprintf("empty app root #%s", root)
+	}
+	if noscriptWarning(doc) {
+		return "noscript warning"
+	}
+	if n := len(visibleText(doc)); n < minBodyText {
+		return fmt.Sprintf("only %d characters of body text", n)
+	}
+	links, err := c.extractLinks(htmlContent)
+	if err == nil && len(links) == 0 {
+		return "no links found"
+	}
+	return ""
+}
+
+// emptyAppRoot returns the id of a known app mount point that has no child elements.
+func emptyAppRoot(n *html.Node) string {
+	if n.Type == html.ElementNode {
+		for _, attr := range n.Attr {
+			if attr.Key == "id" && slices.Contains(appRoots, attr.Val) && !hasElementChild(n) {
+				return attr.Val
+			}
+		}
+	}
+	for child := n.FirstChild; child != nil; child = child.NextSibling {
+		if id := emptyAppRoot(child); id != "" {
+			return id
+		}
+	}
+	return ""
+}
+
+// noscriptWarning reports whether a noscript element asks the reader to enable javascript.
+func noscriptWarning(n *html.Node) bool {
+	if n.Type == html.ElementNode && n.Data == "noscript" {
+		text := strings.ToLower(nodeText(n))
+		return strings.Contains(text, "javascript")
+	}
+	for child := n.FirstChild; child != nil; child = child.NextSibling {
+		if noscriptWarning(child) {
+			return true
+		}
+	}
+	return false
+}
+
+// visibleText returns the text of the page body, skipping scripts, styles and noscript content.
+func visibleText(n *html.Node) string {
+	var sb strings.Builder
+	var f func(*html.Node)
+	f = func(n *html.Node) {
+		if n.Type == html.ElementNode {
+			switch n.Data {
+			case "script", "style", "noscript", "head", "template":
+				return
+			}
+		}
+		if n.Type == html.TextNode {
+			sb.WriteString(strings.TrimSpace(n.Data))
+		}
+		for child := n.FirstChild; child != nil; child = child.NextSibling {
+			f(child)
+		}
+	}
+	f(n)
+	return sb.String()
+}
+
+// nodeText returns all text below a node. The html parser keeps noscript content as raw text.
+func nodeText(n *html.Node) string {
+	var sb strings.Builder
+	for child := n.FirstChild; child != nil; child = child.NextSibling {
+		if child.Type == html.TextNode {
+			sb.WriteString(child.Data)
+		} else {
+			sb.WriteString(nodeText(child))
+		}
+	}
+	return sb.String()
+}
+
+func hasElementChild(n *html.Node) bool {
+	for child := n.FirstChild; child != nil; child = child.NextSibling {
+		if child.Type == html.ElementNode {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,68 @@
+package crawler
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestNeedsJavascript(t *testing.T) {
+	article := strings.Repeat("Plenty of server rendered article text. ", 10)
+	tests := []struct {
+		name string
+		html string
+		want string
+	}{
+		{
+			name: "server rendered page",
+			html: `<html><body><p>` + article + `</p><a href="/next">next</a></body></html>`,
+			want: "",
+		},
+		{
+			name: "empty react root",
+			html: `<html><body><div id="root"></div><script src="/app.js"></script></body></html>`,
+			want: "empty app root #root",
+		},
+		{
+			name: "noscript warning",
+			html: `<html><body><noscript>You need to enable JavaScript to run this app.</noscript><p>` + article + `</p></body></html>`,
+			want: "noscript warning",
+		},
+		{
+			name: "near empty body",
+			html: `<html><head><title>Loading</title></head><body><a href="/x">x</a><script>var a = "` + article + `";</script></body></html>`,
+			want: "only 1 characters of body text",
+		},
+		{
+			name: "no links",
+			html: `<html><body><p>` + article + `</p></body></html>`,
+			want: "no links found",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := NewCrawler()
+			assert.Equal(t, tt.want, c.needsJavascript(tt.html))
+		})
+	}
+}
+
+func TestFetchAutoRemembersHost(t *testing.T) {
+	article := strings.Repeat("Plenty of server rendered article text. ", 10)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write([]byte(`<html><body><p>` + article + `</p><a href="/next">next</a></body></html>`))
+	}))
+	defer server.Close()
+
+	c := NewCrawler()
+	c.Silent = true
+	c.JsAuto = true
+	_, err := c.FetchHTML(server.URL+"/", false)
+	assert.NoError(t, err)
+	useJavascript, decided := c.jsHosts[getDomain(server.URL)]
+	assert.True(t, decided)
+	assert.False(t, useJavascript)
+}
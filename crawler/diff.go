@@ -0,0 +1,273 @@
+package crawler
+
+import (
+	"slices"
+	"strings"
+
+	"golang.org/x/net/html"
+)
+
+// maxDiffCells bounds the work done comparing two texts, larger edits are shown as a full replacement.
+const maxDiffCells = 4_000_000
+
+// CrawlDiff is what changed on a site between two crawls.
+type CrawlDiff struct {
+	Added   []string      `json:"added"`   // pages only in the new crawl
+	Removed []string      `json:"removed"` // pages only in the old crawl
+	Changed []PageChange  `json:"changed"` // pages whose main text changed
+	Titles  []TitleChange `json:"titles"`
+	Broken  []BrokenLink  `json:"broken"` // pages that fail in the new crawl but did not before
+}
+
+// PageChange is a page whose main text changed, Diff has one "-" or "+" prefixed line per changed line.
+type PageChange struct {
+	URL  string `json:"url"`
+	Diff string `json:"diff"`
+}
+
+// TitleChange is a page whose title changed.
+type TitleChange struct {
+	URL string `json:"url"`
+	Old string `json:"old"`
+	New string `json:"new"`
+}
+
+// BrokenLink is a page that could not be fetched and the pages linking to it.
+type BrokenLink struct {
+	URL     string   `json:"url"`
+	Error   string   `json:"error"`
+	Sources []string `json:"sources,omitempty"`
+}
+
+// Diff compares the pages of two crawls of the same site.
+// Content is only compared when both crawls kept it, unchanged pages of an incremental crawl are skipped.
+func Diff(oldPages, newPages []Page) CrawlDiff {
+	diff := CrawlDiff{
+		Added:   []string{},
+		Removed: []string{},
+		Changed: []PageChange{},
+		Titles:  []TitleChange{},
+		Broken:  []BrokenLink{},
+	}
+	before := make(map[string]Page, len(oldPages))
+	for _, p := range oldPages {
+		before[p.URL] = p
+	}
+	after := make(map[string]Page, len(newPages))
+	for _, p := range newPages {
+		after[p.URL] = p
+	}
+	for _, p := range oldPages {
+		if _, ok := after[p.URL]; !ok {
+			diff.Removed = append(diff.Removed, p.URL)
+		}
+	}
+	for _, p := range newPages {
+		old, existed := before[p.URL]
+		if !existed {
+			diff.Added = append(diff.Added, p.URL)
+		}
+		if p.failed() {
+			if !existed || !old.failed() {
+				diff.Broken = append(diff.Broken, BrokenLink{URL: p.URL, Error: p.Result.Error})
+			}
+			continue
+		}
+		if !existed || old.failed() || old.Content == "" || p.Content == "" {
+			continue
+		}
+		if p.Result != nil && p.Result.Status == "unchanged" {
+			continue
+		}
+		if oldTitle, newTitle := pageTitle(old.Content), pageTitle(p.Content); oldTitle != newTitle {
+			diff.Titles = append(diff.Titles, TitleChange{URL: p.URL, Old: oldTitle, New: newTitle})
+		}
+		if text := textDiff(mainText(old.Content), mainText(p.Content)); text != "" {
+			diff.Changed = append(diff.Changed, PageChange{URL: p.URL, Diff: text})
+		}
+	}
+	if len(diff.Broken) > 0 {
+		linkSources(diff.Broken, newPages)
+	}
+	slices.Sort(diff.Added)
+	slices.Sort(diff.Removed)
+	return diff
+}
+
+// failed reports whether the page could not be fetched.
+func (p Page) failed() bool {
+	return p.Result != nil && p.Result.Error != ""
+}
+
+// linkSources fills in the pages that link to each broken page.
+func linkSources(broken []BrokenLink, pages []Page) {
+	index := make(map[string]int, len(broken))
+	for i, b := range broken {
+		index[NormalizeURL(b.URL)] = i
+	}
+	c := NewCrawler()
+	for _, p := range pages {
+		if p.Content == "" {
+			continue
+		}
+		links, err := c.extractLinks(p.Content)
+		if err != nil {
+			continue
+		}
+		for link := range links {
+			i, ok := index[NormalizeURL(toAbsoluteURL(p.URL, link))]
+			if ok && !slices.Contains(broken[i].Sources, p.URL) {
+				broken[i].Sources = append(broken[i].Sources, p.URL)
+			}
+		}
+	}
+	for i := range broken {
+		slices.Sort(broken[i].Sources)
+	}
+}
+
+// pageTitle returns the text of the page's title element.
+func pageTitle(htmlContent string) string {
+	doc, err := html.Parse(strings.NewReader(htmlContent))
+	if err != nil {
+		return ""
+	}
+	var find func(*html.Node) string
+	find = func(n *html.Node) string {
+		if n.Type == html.ElementNode && n.Data == "title" {
+			return strings.Join(strings.Fields(nodeText(n)), " ")
+		}
+		for child := n.FirstChild; child != nil; child = child.NextSibling {
+			if title := find(child); title != "" {
+				return title
+			}
+		}
+		return ""
+	}
+	return find(doc)
+}
+
+// mainText returns the lines of text in the page's main content: the main or article
+// element when there is one, otherwise the body without navigation, headers and footers.
+func mainText(htmlContent string) []string {
+	doc, err := html.Parse(strings.NewReader(htmlContent))
+	if err != nil {
+		return nil
+	}
+	root := findElement(doc, "main")
+	if root == nil {
+		root = findElement(doc, "article")
+	}
+	if root == nil {
+		root = doc
+	}
+	lines := []string{}
+	var line strings.Builder
+	flush := func() {
+		if text := strings.Join(strings.Fields(line.String()), " "); text != "" {
+			lines = append(lines, text)
+		}
+		line.Reset()
+	}
+	var f func(*html.Node)
+	f = func(n *html.Node) {
+		if n.Type == html.ElementNode {
+			switch n.Data {
+			case "script", "style", "noscript", "head", "template", "nav", "header", "footer", "aside":
+				return
+			}
+		}
+		if n.Type == html.TextNode {
+			line.WriteString(n.Data)
+			line.WriteString(" ")
+		}
+		block := n.Type == html.ElementNode && isBlockElement(n.Data)
+		if block {
+			flush()
+		}
+		for child := n.FirstChild; child != nil; child = child.NextSibling {
+			f(child)
+		}
+		if block {
+			flush()
+		}
+	}
+	f(root)
+	flush()
+	return lines
+}
+
+func findElement(n *html.Node, tag string) *html.Node {
+	if n.Type == html.ElementNode && n.Data == tag {
+		return n
+	}
+	for child := n.FirstChild; child != nil; child = child.NextSibling {
+		if found := findElement(child, tag); found != nil {
+			return found
+		}
+	}
+	return nil
+}
+
+func isBlockElement(tag string) bool {
+	switch tag {
+	case "p", "div", "section", "article", "main", "li", "ul", "ol", "dl", "dt", "dd", "table", "tr", "td", "th",
+		"h1", "h2", "h3", "h4", "h5", "h6", "blockquote", "pre", "br", "hr", "figure", "figcaption", "body":
+		return true
+	}
+	return false
+}
+
+// textDiff returns the lines removed from a ("- ") and added in b ("+ "), in order,
+// or an empty string when both are the same.
+func textDiff(a, b []string) string {
+	// common prefix and suffix need no comparing
+	for len(a) > 0 && len(b) > 0 && a[0] == b[0] {
+		a, b = a[1:], b[1:]
+	}
+	for len(a) > 0 && len(b) > 0 && a[len(a)-1] == b[len(b)-1] {
+		a, b = a[:len(a)-1], b[:len(b)-1]
+	}
+	if len(a) == 0 && len(b) == 0 {
+		return ""
+	}
+	var sb strings.Builder
+	if len(a)*len(b) > maxDiffCells {
+		for _, line := range a {
+			sb.WriteString("- " + line + "\n")
+		}
+		for _, line := range b {
+			sb.WriteString("+ " + line + "\n")
+		}
+		return sb.String()
+	}
+	// longest common subsequence, lcs[i][j] is the length for a[i:] and b[j:]
+	lcs := make([][]int, len(a)+1)
+	for i := range lcs {
+		lcs[i] = make([]int, len(b)+1)
+	}
+	for i := len(a) - 1; i >= 0; i-- {
+		for j := len(b) - 1; j >= 0; j-- {
+			if a[i] == b[j] {
+				lcs[i][j] = lcs[i+1][j+1] + 1
+			} else {
+				lcs[i][j] = max(lcs[i+1][j], lcs[i][j+1])
+			}
+		}
+	}
+	i, j := 0, 0
+	for i < len(a) || j < len(b) {
+		switch {
+		case i < len(a) && j < len(b) && a[i] == b[j]:
+			i++
+			j++
+		case j == len(b) || i < len(a) && lcs[i+1][j] >= lcs[i][j+1]:
+			sb.WriteString("- " + a[i] + "\n")
+			i++
+		default:
+			sb.WriteString("+ " + b[j] + "\n")
+			j++
+		}
+	}
+	return sb.String()
+}
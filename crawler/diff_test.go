@@ -0,0 +1,79 @@
+package crawler
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestDiff(t *testing.T) {
+	page := func(url, content string) Page {
+		return Page{URL: url, Content: content, Result: &PageResult{URL: url}}
+	}
+	broken := func(url string) Page {
+		return Page{URL: url, Result: &PageResult{URL: url, Error: "HTTP 404 404 Not Found"}}
+	}
+	oldPages := []Page{
+		page("https://example.com", `<title>Home</title><nav>menu</nav><main><p>welcome</p><a href="/news">news</a></main>`),
+		page("https://example.com/news", `<title>News</title><main><p>first story</p><p>second story</p></main>`),
+		page("https://example.com/old", `<p>retired</p>`),
+		broken("https://example.com/always-broken"),
+	}
+	newPages := []Page{
+		page("https://example.com", `<title>Welcome</title><nav>new menu</nav><main><p>welcome</p><a href="/news">news</a></main>`),
+		page("https://example.com/about", `<title>About</title><a href="/news">news</a><a href="/always-broken">x</a>`),
+		broken("https://example.com/news"),
+		broken("https://example.com/always-broken"),
+	}
+	newPages[0].Content = `<title>Welcome</title><nav>new menu</nav><main><p>welcome</p><p>third story</p><a href="/news">news</a></main>`
+
+	diff := Diff(oldPages, newPages)
+	assert.Equal(t, []string{"https://example.com/about"}, diff.Added)
+	assert.Equal(t, []string{"https://example.com/old"}, diff.Removed)
+	assert.Equal(t, []TitleChange{{URL: "https://example.com", Old: "Home", New: "Welcome"}}, diff.Titles)
+	assert.Equal(t, []PageChange{{URL: "https://example.com", Diff: "+ third story\n"}}, diff.Changed, "navigation is not main text")
+	assert.Equal(t, []BrokenLink{{
+		URL:     "https://example.com/news",
+		Error:   "HTTP 404 404 Not Found",
+		Sources: []string{"https://example.com", "https://example.com/about"},
+	}}, diff.Broken)
+}
+
+func TestTextDiff(t *testing.T) {
+	tests := []struct {
+		name string
+		a, b []string
+		want string
+	}{
+		{"same", []string{"a", "b"}, []string{"a", "b"}, ""},
+		{"added", []string{"a", "c"}, []string{"a", "b", "c"}, "+ b\n"},
+		{"removed", []string{"a", "b", "c"}, []string{"a", "c"}, "- b\n"},
+		{"replaced", []string{"a", "b", "c"}, []string{"a", "x", "c"}, "- b\n+ x\n"},
+		{"empty", nil, []string{"a"}, "+ a\n"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.want, textDiff(tt.a, tt.b))
+		})
+	}
+}
+
+func TestWriteAndLoadPages(t *testing.T) {
+	pages := []Page{
+		{URL: "https://example.com", Depth: 1, Content: "<p>hi</p>", Result: &PageResult{URL: "https://example.com", Depth: 1}},
+		{URL: "https://example.com/a", Depth: 2},
+	}
+	var buf bytes.Buffer
+	assert.NoError(t, WritePages(&buf, pages))
+	path := filepath.Join(t.TempDir(), "pages.jsonl")
+	assert.NoError(t, os.WriteFile(path, buf.Bytes(), 0o644))
+	loaded, err := LoadPages(path)
+	assert.NoError(t, err)
+	assert.Equal(t, pages, loaded)
+
+	_, err = LoadPages(t.TempDir())
+	assert.Error(t, err, "a directory without crawl state has no pages")
+}
@@ -0,0 +1,146 @@
+package crawler
+
+import (
+	"net/http"
+	"slices"
+	"strings"
+
+	"golang.org/x/net/html"
+)
+
+// nofollowRels are the link types that ask crawlers not to follow a link.
+var nofollowRels = []string{"nofollow", "ugc", "sponsored"}
+
+// robotsKeys are the X-Robots-Tag directives that take a value after a colon, anything
+// else before a colon names the crawler the directives are meant for.
+var robotsKeys = []string{"max-snippet", "max-image-preview", "max-video-preview", "unavailable_after"}
+
+// Stats counts the pages and links crawls left out because of robots directives, and the
+// requests answered by the cache.
+type Stats struct {
+	Noindex       int `json:"noindex"`        // pages fetched but left out of the results
+	Nofollow      int `json:"nofollow"`       // pages whose links were not followed
+	NofollowLinks int `json:"nofollow_links"` // links not followed because of their rel
+	CacheHits     int `json:"cache_hits"`     // requests answered from the cache, including revalidated responses
+	CacheMisses   int `json:"cache_misses"`   // requests the cache had nothing usable for
+}
+
+// Stats returns the counts of the crawls run so far.
+func (c *Crawler) Stats() Stats {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	return c.stats
+}
+
+// robotsDirectives reads the noindex and nofollow directives of a robots meta tag or
+// X-Robots-Tag value. Values meant for a specific crawler ("googlebot: noindex") are ignored.
+func robotsDirectives(value string) (noindex, nofollow bool) {
+	if agent, _, found := strings.Cut(value, ":"); found {
+		agent = strings.ToLower(strings.TrimSpace(agent))
+		if !strings.Contains(agent, ",") && !slices.Contains(robotsKeys, agent) {
+			return false, false
+		}
+	}
+	for _, directive := range strings.Split(value, ",") {
+		switch strings.ToLower(strings.TrimSpace(directive)) {
+		case "noindex":
+			noindex = true
+		case "nofollow":
+			nofollow = true
+		case "none":
+			noindex, nofollow = true, true
+		}
+	}
+	return noindex, nofollow
+}
+
+// headerDirectives applies the X-Robots-Tag headers of a page when RobotsDirectives is set.
+func (c *Crawler) headerDirectives(pageURL string, header http.Header) {
+	if !c.RobotsDirectives {
+		return
+	}
+	for _, value := range header.Values("X-Robots-Tag") {
+		noindex, nofollow := robotsDirectives(value)
+		c.markDirectives(pageURL, noindex, nofollow)
+	}
+}
+
+// metaDirectives applies the robots meta tags of a page when RobotsDirectives is set.
+// Only the head is read, the tags don't count in the body.
+func (c *Crawler) metaDirectives(pageURL, htmlContent string) {
+	if !c.RobotsDirectives {
+		return
+	}
+	z := html.NewTokenizer(strings.NewReader(htmlContent))
+	for {
+		switch z.Next() {
+		case html.ErrorToken:
+			return
+		case html.StartTagToken, html.SelfClosingTagToken:
+			name, hasAttr := z.TagName()
+			switch string(name) {
+			case "body":
+				return
+			case "meta":
+				attrs := map[string]string{}
+				for hasAttr {
+					var key, val []byte
+					key, val, hasAttr = z.TagAttr()
+					attrs[string(key)] = string(val)
+				}
+				if strings.EqualFold(strings.TrimSpace(attrs["name"]), "robots") {
+					noindex, nofollow := robotsDirectives(attrs["content"])
+					c.markDirectives(pageURL, noindex, nofollow)
+				}
+			}
+		}
+	}
+}
+
+// markDirectives records the directives found for a page, counting each page once.
+func (c *Crawler) markDirectives(pageURL string, noindex, nofollow bool) {
+	if !noindex && !nofollow {
+		return
+	}
+	c.recordPage(pageURL, func(r *PageResult) {
+		if noindex && !r.Noindex {
+			r.Noindex = true
+			c.stats.Noindex++
+		}
+		if nofollow && !r.Nofollow {
+			r.Nofollow = true
+			c.stats.Nofollow++
+		}
+	})
+}
+
+// followed drops the links marked nofollow, ugc or sponsored, and every link of a nofollow
+// page, when RobotsDirectives is set.
+func (c *Crawler) followed(pageURL string, anchors []anchor) []anchor {
+	if !c.RobotsDirectives {
+		return anchors
+	}
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	if r, ok := c.pageResults[pageURL]; ok && r.Nofollow {
+		return nil
+	}
+	kept := make([]anchor, 0, len(anchors))
+	for _, a := range anchors {
+		rel := strings.Fields(strings.ToLower(a.rel))
+		if slices.ContainsFunc(nofollowRels, func(r string) bool { return slices.Contains(rel, r) }) {
+			c.stats.NofollowLinks++
+			continue
+		}
+		kept = append(kept, a)
+	}
+	return kept
+}
+
+// noindex reports whether a page asked to be left out of the results.
+func (c *Crawler) noindex(pageURL string) bool {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	r, ok := c.pageResults[pageURL]
+	return ok && r.Noindex
+}
@@ -0,0 +1,102 @@
+package crawler
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRobotsDirectives(t *testing.T) {
+	tests := []struct {
+		value        string
+		wantNoindex  bool
+		wantNofollow bool
+	}{
+		{"noindex", true, false},
+		{"NOINDEX, NoFollow", true, true},
+		{"none", true, true},
+		{"index, follow", false, false},
+		{"googlebot: noindex", false, false},
+		{"noindex, max-snippet:50", true, false},
+		{"max-image-preview:large, nofollow", false, true},
+		{"unavailable_after: 2026-01-01", false, false},
+		{"", false, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.value, func(t *testing.T) {
+			noindex, nofollow := robotsDirectives(tt.value)
+			assert.Equal(t, tt.wantNoindex, noindex)
+			assert.Equal(t, tt.wantNofollow, nofollow)
+		})
+	}
+}
+
+func TestRespectDirectives(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/html")
+		switch r.URL.Path {
+		case "/":
+			_, _ = w.Write([]byte(`<a href="/noindex">a</a><a href="/nofollow">b</a><a href="/header">c</a>
+				<a href="/googlebot">d</a><a href="/body-meta">e</a>
+				<a href="/ad" rel="sponsored">ad</a><a href="/comment" rel="ugc nofollow">comment</a>`))
+		case "/noindex":
+			_, _ = w.Write([]byte(`<head><meta name="robots" content="noindex"></head><a href="/from-noindex">x</a>`))
+		case "/nofollow":
+			_, _ = w.Write([]byte(`<head><meta name="Robots" content="index, nofollow"></head><a href="/from-nofollow">x</a>`))
+		case "/header":
+			w.Header().Set("X-Robots-Tag", "noindex")
+			_, _ = w.Write([]byte(`page`))
+		case "/googlebot":
+			w.Header().Set("X-Robots-Tag", "googlebot: noindex, nofollow")
+			_, _ = w.Write([]byte(`<a href="/from-googlebot">x</a>`))
+		case "/body-meta":
+			_, _ = w.Write([]byte(`<html><body><meta name="robots" content="none"></body></html>`))
+		default:
+			_, _ = w.Write([]byte(`page`))
+		}
+	}))
+	defer server.Close()
+
+	all := []string{"/", "/ad", "/body-meta", "/comment", "/from-googlebot", "/from-nofollow", "/from-noindex",
+		"/googlebot", "/header", "/nofollow", "/noindex"}
+	tests := []struct {
+		name       string
+		directives bool
+		want       []string
+		wantStats  Stats
+	}{
+		{"ignored", false, all, Stats{}},
+		{"honored", true, []string{"/", "/body-meta", "/from-googlebot", "/from-noindex", "/googlebot", "/nofollow"},
+			Stats{Noindex: 2, Nofollow: 1, NofollowLinks: 2}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := NewCrawler()
+			c.Silent = true
+			c.MaxDepth = 3
+			c.RobotsDirectives = tt.directives
+			pages, err := c.Crawl(server.URL + "/")
+			assert.NoError(t, err)
+			crawled := []string{}
+			for url := range pages {
+				crawled = append(crawled, strings.TrimPrefix(url, server.URL))
+			}
+			slices.Sort(crawled)
+			assert.Equal(t, tt.want, crawled)
+			assert.Equal(t, tt.want, crawledPaths(c, server.URL))
+			assert.Equal(t, tt.wantStats, c.Stats())
+		})
+	}
+
+	c := NewCrawler()
+	c.Silent = true
+	c.RobotsDirectives = true
+	items, err := c.Collect(server.URL+"/", server.URL+"/noindex")
+	assert.NoError(t, err)
+	assert.NotContains(t, items, server.URL+"/noindex")
+	assert.Contains(t, items, server.URL+"/")
+}
@@ -0,0 +1,315 @@
+package crawler
+
+import (
+	"bytes"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"slices"
+	"strings"
+	"time"
+
+	"golang.org/x/net/html"
+	"golang.org/x/net/html/charset"
+)
+
+// feedTypes are the link types pages use to advertise their feeds.
+var feedTypes = []string{"application/rss+xml", "application/atom+xml"}
+
+// feedDateFormats are the date forms found in RSS pubDate and Atom published.
+var feedDateFormats = []string{
+	time.RFC1123Z, time.RFC1123, time.RFC822Z, time.RFC822, time.RFC3339,
+	"Mon, 2 Jan 2006 15:04:05 -0700", "Mon, 2 Jan 2006 15:04:05 MST", "2 Jan 2006 15:04:05 -0700",
+	"2006-01-02T15:04:05", "2006-01-02",
+}
+
+// FeedEntry is an item of an RSS or Atom feed.
+type FeedEntry struct {
+	URL       string    `json:"url"`
+	Title     string    `json:"title,omitempty"`
+	Published time.Time `json:"published,omitzero"`
+	Feed      string    `json:"feed"` // the feed the entry was read from
+}
+
+// feedXML decodes RSS 2.0, RSS 1.0 and Atom documents.
+type feedXML struct {
+	XMLName xml.Name
+	Channel struct {
+		Items []rssItem `xml:"item"`
+	} `xml:"channel"`
+	Items   []rssItem   `xml:"item"` // RSS 1.0 puts items next to the channel
+	Entries []atomEntry `xml:"entry"`
+}
+
+type rssItem struct {
+	Title   string `xml:"title"`
+	Link    string `xml:"link"`
+	PubDate string `xml:"pubDate"`
+	Date    string `xml:"date"` // dc:date
+	GUID    struct {
+		Value       string `xml:",chardata"`
+		IsPermaLink string `xml:"isPermaLink,attr"`
+	} `xml:"guid"`
+}
+
+type atomEntry struct {
+	Title string `xml:"title"`
+	Links []struct {
+		Href string `xml:"href,attr"`
+		Rel  string `xml:"rel,attr"`
+	} `xml:"link"`
+	Published string `xml:"published"`
+	Updated   string `xml:"updated"`
+}
+
+// ReadFeed downloads an RSS or Atom feed and returns its entries.
+func (c *Crawler) ReadFeed(feedURL string) ([]FeedEntry, error) {
+	body, err := c.fetchBody(feedURL)
+	if err != nil {
+		return nil, err
+	}
+	entries, ok := parseFeed(feedURL, body)
+	if !ok {
+		return nil, fmt.Errorf("invalid feed %s", feedURL)
+	}
+	return entries, nil
+}
+
+// parseFeed returns the entries of an RSS or Atom document, or false when body is not a feed.
+func parseFeed(feedURL string, body []byte) ([]FeedEntry, bool) {
+	var doc feedXML
+	decoder := xml.NewDecoder(bytes.NewReader(body))
+	// older feeds are often declared as ISO-8859-1 or windows-1252
+	decoder.CharsetReader = charset.NewReaderLabel
+	if err := decoder.Decode(&doc); err != nil {
+		return nil, false
+	}
+	entries := []FeedEntry{}
+	add := func(link, title, published string) {
+		link = strings.TrimSpace(link)
+		if link == "" {
+			return
+		}
+		entries = append(entries, FeedEntry{
+			URL:       toAbsoluteURL(feedURL, link),
+			Title:     strings.TrimSpace(title),
+			Published: parseFeedDate(published),
+			Feed:      feedURL,
+		})
+	}
+	switch doc.XMLName.Local {
+	case "rss", "RDF":
+		for _, item := range append(doc.Channel.Items, doc.Items...) {
+			link := item.Link
+			if link == "" && item.GUID.IsPermaLink != "false" {
+				link = item.GUID.Value
+			}
+			published := item.PubDate
+			if published == "" {
+				published = item.Date
+			}
+			add(link, item.Title, published)
+		}
+	case "feed":
+		for _, entry := range doc.Entries {
+			link := ""
+			for _, l := range entry.Links {
+				if l.Rel == "" || l.Rel == "alternate" {
+					link = l.Href
+					break
+				}
+			}
+			published := entry.Published
+			if published == "" {
+				published = entry.Updated
+			}
+			add(link, entry.Title, published)
+		}
+	default:
+		return nil, false
+	}
+	return entries, true
+}
+
+// parseFeedDate parses an entry date, returning the zero time when it is missing or invalid.
+func parseFeedDate(value string) time.Time {
+	value = strings.TrimSpace(value)
+	for _, layout := range feedDateFormats {
+		if t, err := time.Parse(layout, value); err == nil {
+			return t
+		}
+	}
+	return time.Time{}
+}
+
+// feedLinks returns the feeds a page advertises with <link rel="alternate">.
+func feedLinks(pageURL, htmlContent string) []string {
+	doc, err := html.Parse(strings.NewReader(htmlContent))
+	if err != nil {
+		return nil
+	}
+	feeds := []string{}
+	var f func(*html.Node)
+	f = func(n *html.Node) {
+		if n.Type == html.ElementNode && n.Data == "link" {
+			var rel, typ, href string
+			for _, attr := range n.Attr {
+				switch attr.Key {
+				case "rel":
+					rel = attr.Val
+				case "type":
+					typ = attr.Val
+				case "href":
+					href = attr.Val
+				}
+			}
+			if href != "" && slices.Contains(strings.Fields(strings.ToLower(rel)), "alternate") &&
+				slices.Contains(feedTypes, strings.ToLower(strings.TrimSpace(typ))) {
+				feed := toAbsoluteURL(pageURL, href)
+				if !slices.Contains(feeds, feed) {
+					feeds = append(feeds, feed)
+				}
+			}
+		}
+		for child := n.FirstChild; child != nil; child = child.NextSibling {
+			f(child)
+		}
+	}
+	f(doc)
+	return feeds
+}
+
+// feedSeeds replaces seeds that are feeds with their entries, keeping entry metadata for
+// the page results. Seeds are told apart by their content type so the body of a page is
+// only downloaded by the crawl, which reads the feeds it advertises then (see seedFeeds).
+func (c *Crawler) feedSeeds(seeds []string) []string {
+	pages := []string{}
+	entries := []FeedEntry{}
+	for _, seed := range seeds {
+		if found, ok := c.seedFeed(seed); ok {
+			entries = append(entries, found...)
+			continue
+		}
+		pages = append(pages, seed)
+		c.mutex.Lock()
+		c.feedPages[c.normalize(seed)] = true
+		c.mutex.Unlock()
+	}
+	for _, entry := range c.addFeedEntries(entries) {
+		pages = append(pages, entry.URL)
+	}
+	return pages
+}
+
+// seedFeed returns the entries of a seed that is a feed. The content type comes from a HEAD
+// request, or from the headers of the GET when the server won't answer HEAD; the body is
+// only read when the type can be a feed, the GET of a page is closed without reading it.
+func (c *Crawler) seedFeed(seed string) ([]FeedEntry, bool) {
+	resp, err := c.seedResponse(http.MethodHead, seed)
+	if err != nil {
+		// the crawl reports the error when it fetches the seed
+		return nil, false
+	}
+	_ = resp.Body.Close()
+	if resp.StatusCode == http.StatusOK && resp.Header.Get("Content-Type") != "" && !isFeedType(resp.Header.Get("Content-Type")) {
+		return nil, false
+	}
+	resp, err = c.seedResponse(http.MethodGet, seed)
+	if err != nil {
+		return nil, false
+	}
+	defer func() {
+		_ = resp.Body.Close()
+	}()
+	if resp.StatusCode != http.StatusOK || resp.Header.Get("Content-Type") != "" && !isFeedType(resp.Header.Get("Content-Type")) {
+		return nil, false
+	}
+	body, err := io.ReadAll(io.LimitReader(resp.Body, maxSitemapSize))
+	if err != nil {
+		return nil, false
+	}
+	return parseFeed(seed, body)
+}
+
+// seedResponse sends a request for a seed, the caller closes the body.
+func (c *Crawler) seedResponse(method, seed string) (*http.Response, error) {
+	req, err := http.NewRequest(method, seed, nil)
+	if err != nil {
+		return nil, err
+	}
+	return c.do(req)
+}
+
+// isFeedType reports whether a content type can be an RSS or Atom feed.
+func isFeedType(contentType string) bool {
+	mediaType, _, err := mime.ParseMediaType(contentType)
+	if err != nil {
+		return false
+	}
+	return slices.Contains(feedTypes, mediaType) || mediaType == "application/rdf+xml" ||
+		mediaType == "application/xml" || mediaType == "text/xml"
+}
+
+// seedFeeds reads the feeds a seed page advertises with <link rel="alternate"> and queues
+// their entries as depth 1 pages.
+func (c *Crawler) seedFeeds(pageURL, htmlContent string) {
+	c.mutex.Lock()
+	seed := c.feedPages[pageURL]
+	delete(c.feedPages, pageURL)
+	q := c.queue
+	c.mutex.Unlock()
+	if !seed {
+		return
+	}
+	entries := []FeedEntry{}
+	for _, feed := range feedLinks(c.finalURL(pageURL), htmlContent) {
+		found, err := c.ReadFeed(feed)
+		if err != nil {
+			if !c.Silent {
+				fmt.Printf("Warning: %v\n", err)
+			}
+			continue
+		}
+		if !c.Silent {
+			fmt.Printf("found %d entries in feed %s\n", len(found), feed)
+		}
+		entries = append(entries, found...)
+	}
+	if q == nil {
+		return
+	}
+	for _, entry := range c.addFeedEntries(entries) {
+		c.enqueue(q, entry.URL, entry.Title, 1)
+	}
+}
+
+// addFeedEntries keeps the entries within the crawl rules that weren't found before and returns them.
+func (c *Crawler) addFeedEntries(entries []FeedEntry) []FeedEntry {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	added := []FeedEntry{}
+	for _, entry := range entries {
+		if !c.validDomainCheck(entry.URL) || !c.linkTextCheck(entry.URL, entry.Title) {
+			continue
+		}
+		url := c.normalize(entry.URL)
+		if _, ok := c.feedEntries[url]; !ok {
+			c.feedEntries[url] = entry
+			added = append(added, entry)
+		}
+	}
+	return added
+}
+
+// attachFeed adds the feed entry a page was found in to its result.
+func (c *Crawler) attachFeed(pageURL string) {
+	c.mutex.Lock()
+	entry, ok := c.feedEntries[pageURL]
+	r, crawled := c.pageResults[pageURL]
+	if ok && crawled {
+		r.Feed = &entry
+	}
+	c.mutex.Unlock()
+}
@@ -0,0 +1,186 @@
+package crawler
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+const rssFeed = `<?xml version="1.0"?>
+<rss version="2.0"><channel><title>News</title>
+  <item><title>Storm hits coast</title><link>/news/storm</link><pubDate>Mon, 04 May 2026 09:30:00 +0000</pubDate></item>
+  <item><title>Permalink only</title><guid>SITE/news/guid</guid></item>
+  <item><title>Not a link</title><guid isPermaLink="false">tag:1234</guid></item>
+</channel></rss>`
+
+const atomFeed = `<?xml version="1.0" encoding="utf-8"?>
+<feed xmlns="http://www.w3.org/2005/Atom"><title>Blog</title>
+  <entry><title>Launch day</title><link rel="alternate" href="SITE/blog/launch"/><link rel="edit" href="SITE/edit/1"/>
+    <published>2026-05-02T12:00:00Z</published></entry>
+  <entry><title>Updated only</title><link href="SITE/blog/updated"/><updated>2026-05-03T12:00:00Z</updated></entry>
+</feed>`
+
+// feedSite serves a page advertising an RSS feed and an Atom feed, calling seen, when set, for each request.
+func feedSite(seen func(r *http.Request)) *httptest.Server {
+	var server *httptest.Server
+	server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if seen != nil {
+			seen(r)
+		}
+		site := func(s string) string { return strings.ReplaceAll(s, "SITE", server.URL) }
+		switch r.URL.Path {
+		case "/":
+			_, _ = w.Write([]byte(`<html><head><link rel="alternate" type="application/rss+xml" href="/rss.xml"></head><body>home</body></html>`))
+		case "/rss.xml":
+			w.Header().Set("Content-Type", "application/rss+xml")
+			_, _ = w.Write([]byte(site(rssFeed)))
+		case "/atom.xml":
+			w.Header().Set("Content-Type", "application/atom+xml")
+			_, _ = w.Write([]byte(site(atomFeed)))
+		default:
+			_, _ = w.Write([]byte(`<p>` + r.URL.Path + `</p>`))
+		}
+	}))
+	return server
+}
+
+func TestReadFeed(t *testing.T) {
+	server := feedSite(nil)
+	defer server.Close()
+	c := NewCrawler()
+
+	entries, err := c.ReadFeed(server.URL + "/rss.xml")
+	assert.NoError(t, err)
+	assert.Equal(t, []FeedEntry{
+		{URL: server.URL + "/news/storm", Title: "Storm hits coast", Published: time.Date(2026, 5, 4, 9, 30, 0, 0, time.UTC), Feed: server.URL + "/rss.xml"},
+		{URL: server.URL + "/news/guid", Title: "Permalink only", Feed: server.URL + "/rss.xml"},
+	}, normalizeTimes(entries))
+
+	entries, err = c.ReadFeed(server.URL + "/atom.xml")
+	assert.NoError(t, err)
+	assert.Equal(t, []FeedEntry{
+		{URL: server.URL + "/blog/launch", Title: "Launch day", Published: time.Date(2026, 5, 2, 12, 0, 0, 0, time.UTC), Feed: server.URL + "/atom.xml"},
+		{URL: server.URL + "/blog/updated", Title: "Updated only", Published: time.Date(2026, 5, 3, 12, 0, 0, 0, time.UTC), Feed: server.URL + "/atom.xml"},
+	}, normalizeTimes(entries))
+
+	_, err = c.ReadFeed(server.URL + "/")
+	assert.Error(t, err, "html pages are not feeds")
+}
+
+// normalizeTimes puts feed dates in UTC so they compare equal.
+func normalizeTimes(entries []FeedEntry) []FeedEntry {
+	for i := range entries {
+		if !entries[i].Published.IsZero() {
+			entries[i].Published = entries[i].Published.UTC()
+		}
+	}
+	return entries
+}
+
+func TestFeedLinks(t *testing.T) {
+	page := `<head>
+		<link rel="alternate" type="application/rss+xml" href="/rss">
+		<link rel="Alternate" type="application/atom+xml" href="https://feeds.example.com/atom">
+		<link rel="alternate" hreflang="de" href="/de">
+		<link rel="stylesheet" type="text/css" href="/style.css">
+	</head>`
+	assert.Equal(t, []string{"https://example.com/rss", "https://feeds.example.com/atom"}, feedLinks("https://example.com/page", page))
+}
+
+func TestCrawlFeeds(t *testing.T) {
+	var mutex sync.Mutex
+	downloads := map[string]int{}
+	server := feedSite(func(r *http.Request) {
+		if r.Method == http.MethodGet {
+			mutex.Lock()
+			downloads[r.URL.Path]++
+			mutex.Unlock()
+		}
+	})
+	defer server.Close()
+	c := NewCrawler()
+	c.Silent = true
+	c.MaxDepth = 1
+	c.Feeds = true
+	results, err := c.Crawl(server.URL, server.URL+"/atom.xml")
+	assert.NoError(t, err)
+	assert.Contains(t, results, server.URL+"/")
+	assert.NotContains(t, results, server.URL+"/atom.xml", "feed seeds are replaced by their entries")
+	for _, page := range []string{"/news/storm", "/news/guid", "/blog/launch", "/blog/updated"} {
+		assert.Contains(t, results, server.URL+page, "feed entries are depth 1 pages")
+	}
+	for _, r := range c.Results() {
+		if r.URL == server.URL+"/news/storm" {
+			assert.Equal(t, 1, r.Depth)
+			if assert.NotNil(t, r.Feed) {
+				assert.Equal(t, "Storm hits coast", r.Feed.Title)
+				assert.Equal(t, server.URL+"/rss.xml", r.Feed.Feed)
+			}
+		}
+		if r.URL == server.URL+"/" {
+			assert.Nil(t, r.Feed)
+		}
+	}
+	mutex.Lock()
+	defer mutex.Unlock()
+	for path, n := range downloads {
+		assert.Equal(t, 1, n, "%s is downloaded once", path)
+	}
+	assert.Len(t, downloads, 7)
+}
+
+func TestFeedSeedsWithoutHead(t *testing.T) {
+	var mutex sync.Mutex
+	completed, abandoned := 0, 0
+	var server *httptest.Server
+	server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method == http.MethodHead {
+			w.WriteHeader(http.StatusMethodNotAllowed)
+			return
+		}
+		switch r.URL.Path {
+		case "/":
+			w.Header().Set("Content-Type", "text/html")
+			_, _ = w.Write([]byte(`<html><body>`))
+			w.(http.Flusher).Flush()
+			// the rest of the page is only sent to a client still reading it
+			select {
+			case <-r.Context().Done():
+				mutex.Lock()
+				abandoned++
+				mutex.Unlock()
+				return
+			case <-time.After(100 * time.Millisecond):
+			}
+			_, _ = w.Write([]byte(`home</body></html>`))
+			mutex.Lock()
+			completed++
+			mutex.Unlock()
+		case "/rss.xml":
+			w.Header().Set("Content-Type", "application/rss+xml")
+			_, _ = w.Write([]byte(strings.ReplaceAll(rssFeed, "SITE", server.URL)))
+		default:
+			w.Header().Set("Content-Type", "text/html")
+			_, _ = w.Write([]byte(`<p>` + r.URL.Path + `</p>`))
+		}
+	}))
+	defer server.Close()
+	c := NewCrawler()
+	c.Silent = true
+	c.MaxDepth = 1
+	c.Feeds = true
+	results, err := c.Crawl(server.URL+"/", server.URL+"/rss.xml")
+	assert.NoError(t, err)
+	assert.Equal(t, "<html><body>home</body></html>", results[server.URL+"/"])
+	assert.Contains(t, results, server.URL+"/news/storm", "feeds are told apart without HEAD")
+	assert.NotContains(t, results, server.URL+"/rss.xml")
+	mutex.Lock()
+	defer mutex.Unlock()
+	assert.Equal(t, 1, completed, "the page is only downloaded by the crawl")
+	assert.Equal(t, 1, abandoned, "the seed check stops after the headers")
+}
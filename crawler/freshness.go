@@ -0,0 +1,144 @@
+package crawler
+
+import (
+	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// heuristicStatuses are the status codes a response without explicit freshness may still
+// be stored and reused for, as listed by RFC 9110.
+var heuristicStatuses = []int{200, 203, 204, 300, 301, 308, 404, 405, 410, 414, 501}
+
+// cacheControl parses the Cache-Control directives of a header, lower cased.
+func cacheControl(header http.Header) map[string]string {
+	directives := make(map[string]string)
+	for _, value := range header.Values("Cache-Control") {
+		for _, directive := range strings.Split(value, ",") {
+			name, arg, _ := strings.Cut(strings.TrimSpace(directive), "=")
+			if name != "" {
+				directives[strings.ToLower(name)] = strings.Trim(arg, `"`)
+			}
+		}
+	}
+	return directives
+}
+
+// isStorable reports whether a response to a GET request may be stored (RFC 9111 section 3).
+func isStorable(req *http.Request, resp *http.Response) bool {
+	if _, ok := cacheControl(req.Header)["no-store"]; ok {
+		return false
+	}
+	directives := cacheControl(resp.Header)
+	if _, ok := directives["no-store"]; ok {
+		return false
+	}
+	if resp.Header.Get("Vary") == "*" || resp.StatusCode == http.StatusPartialContent {
+		return false
+	}
+	if _, ok := directives["max-age"]; ok {
+		return true
+	}
+	if _, ok := directives["public"]; ok {
+		return true
+	}
+	return resp.Header.Get("Expires") != "" || slices.Contains(heuristicStatuses, resp.StatusCode)
+}
+
+// isFresh reports whether a stored response can be used without revalidating it
+// (RFC 9111 section 4.2), honoring no-cache and max-age in the request too.
+func isFresh(e *cacheEntry, req *http.Request, now time.Time) bool {
+	requested := cacheControl(req.Header)
+	if _, ok := requested["no-cache"]; ok {
+		return false
+	}
+	if len(req.Header.Values("Cache-Control")) == 0 && strings.EqualFold(req.Header.Get("Pragma"), "no-cache") {
+		return false
+	}
+	if _, ok := cacheControl(e.Header)["no-cache"]; ok {
+		return false
+	}
+	age := currentAge(e, now)
+	if maxAge, ok := requested["max-age"]; ok {
+		if seconds, err := strconv.Atoi(maxAge); err == nil && age > time.Duration(seconds)*time.Second {
+			return false
+		}
+	}
+	return freshnessLifetime(e) > age
+}
+
+// freshnessLifetime is how long a response stays fresh: its max-age, its Expires date, or
+// for responses without either a tenth of the time since it was last modified.
+func freshnessLifetime(e *cacheEntry) time.Duration {
+	directives := cacheControl(e.Header)
+	if maxAge, ok := directives["max-age"]; ok {
+		seconds, err := strconv.Atoi(maxAge)
+		if err != nil || seconds < 0 {
+			return 0
+		}
+		return time.Duration(seconds) * time.Second
+	}
+	date := responseDate(e)
+	if expires := e.Header.Get("Expires"); expires != "" {
+		t, err := http.ParseTime(expires)
+		if err != nil {
+			return 0 // invalid dates such as "0" mean already expired
+		}
+		return t.Sub(date)
+	}
+	if !slices.Contains(heuristicStatuses, e.StatusCode) {
+		return 0
+	}
+	if lastModified, err := http.ParseTime(e.Header.Get("Last-Modified")); err == nil && lastModified.Before(date) {
+		return date.Sub(lastModified) / 10
+	}
+	return 0
+}
+
+// currentAge is how old a stored response is, counting the Age it arrived with and the
+// time it spent in transit (RFC 9111 section 4.2.3).
+func currentAge(e *cacheEntry, now time.Time) time.Duration {
+	apparentAge := max(0, e.ResponseTime.Sub(responseDate(e)))
+	ageValue := time.Duration(0)
+	if seconds, err := strconv.Atoi(e.Header.Get("Age")); err == nil && seconds > 0 {
+		ageValue = time.Duration(seconds) * time.Second
+	}
+	correctedAge := ageValue + e.ResponseTime.Sub(e.RequestTime)
+	return max(apparentAge, correctedAge) + now.Sub(e.ResponseTime)
+}
+
+// responseDate is the Date of a stored response, or when it was received without one.
+func responseDate(e *cacheEntry) time.Time {
+	if date, err := http.ParseTime(e.Header.Get("Date")); err == nil {
+		return date
+	}
+	return e.ResponseTime
+}
+
+// varyHeaders keeps the request headers a response varies on.
+func varyHeaders(req *http.Request, header http.Header) http.Header {
+	vary := http.Header{}
+	for _, value := range header.Values("Vary") {
+		for _, name := range strings.Split(value, ",") {
+			if name = strings.TrimSpace(name); name != "" {
+				vary[http.CanonicalHeaderKey(name)] = req.Header.Values(name)
+			}
+		}
+	}
+	return vary
+}
+
+// varyMatches reports whether a request sends the same headers as the one a response was stored for.
+func varyMatches(e *cacheEntry, req *http.Request) bool {
+	if e.Header.Get("Vary") == "*" {
+		return false
+	}
+	for name, values := range e.Vary {
+		if strings.Join(req.Header.Values(name), ",") != strings.Join(values, ",") {
+			return false
+		}
+	}
+	return true
+}
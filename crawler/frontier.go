@@ -0,0 +1,151 @@
+package crawler
+
+import (
+	"container/heap"
+	"fmt"
+	"strings"
+)
+
+// Entry is a URL waiting in the frontier to be crawled.
+type Entry struct {
+	URL      string  `json:"url"`            // the normalized url
+	Link     string  `json:"link,omitempty"` // the url as it was linked, fetched in place of URL when normalizing changed it
+	Depth    int     `json:"depth"`
+	Priority float64 `json:"priority,omitempty"`
+}
+
+// Frontier decides the order pages are crawled in.
+// Implementations don't need to be safe for concurrent use, the crawler serializes access.
+type Frontier interface {
+	Push(Entry)
+	Pop() (Entry, bool)
+	Len() int
+}
+
+// ScoreFunc ranks a discovered link for the priority order, higher scores are crawled first.
+type ScoreFunc func(pageURL, linkText string, depth int) float64
+
+// NewFrontier returns the built-in frontier for an order: "bfs", "dfs" or "priority".
+func NewFrontier(order string) (Frontier, error) {
+	switch order {
+	case "", "bfs":
+		return &bfsFrontier{}, nil
+	case "dfs":
+		return &dfsFrontier{}, nil
+	case "priority":
+		return &priorityFrontier{}, nil
+	}
+	return nil, fmt.Errorf("unknown crawl order %q, expected bfs, dfs or priority", order)
+}
+
+// bfsFrontier crawls pages in the order they were discovered, shallowest first.
+type bfsFrontier struct {
+	entries []Entry
+}
+
+func (f *bfsFrontier) Push(e Entry) {
+	f.entries = append(f.entries, e)
+}
+
+func (f *bfsFrontier) Pop() (Entry, bool) {
+	if len(f.entries) == 0 {
+		return Entry{}, false
+	}
+	e := f.entries[0]
+	f.entries[0] = Entry{}
+	f.entries = f.entries[1:]
+	return e, true
+}
+
+func (f *bfsFrontier) Len() int {
+	return len(f.entries)
+}
+
+// dfsFrontier crawls the most recently discovered page first.
+type dfsFrontier struct {
+	entries []Entry
+}
+
+func (f *dfsFrontier) Push(e Entry) {
+	f.entries = append(f.entries, e)
+}
+
+func (f *dfsFrontier) Pop() (Entry, bool) {
+	if len(f.entries) == 0 {
+		return Entry{}, false
+	}
+	e := f.entries[len(f.entries)-1]
+	f.entries = f.entries[:len(f.entries)-1]
+	return e, true
+}
+
+func (f *dfsFrontier) Len() int {
+	return len(f.entries)
+}
+
+// priorityFrontier crawls the highest priority page first, breaking ties by depth then discovery order.
+type priorityFrontier struct {
+	entries priorityHeap
+	pushed  int
+}
+
+func (f *priorityFrontier) Push(e Entry) {
+	heap.Push(&f.entries, priorityEntry{Entry: e, seq: f.pushed})
+	f.pushed++
+}
+
+func (f *priorityFrontier) Pop() (Entry, bool) {
+	if len(f.entries) == 0 {
+		return Entry{}, false
+	}
+	return heap.Pop(&f.entries).(priorityEntry).Entry, true
+}
+
+func (f *priorityFrontier) Len() int {
+	return len(f.entries)
+}
+
+type priorityEntry struct {
+	Entry
+	seq int
+}
+
+type priorityHeap []priorityEntry
+
+func (h priorityHeap) Len() int { return len(h) }
+
+func (h priorityHeap) Less(i, j int) bool {
+	if h[i].Priority != h[j].Priority {
+		return h[i].Priority > h[j].Priority
+	}
+	if h[i].Depth != h[j].Depth {
+		return h[i].Depth < h[j].Depth
+	}
+	return h[i].seq < h[j].seq
+}
+
+func (h priorityHeap) Swap(i, j int) { h[i], h[j] = h[j], h[i] }
+
+func (h *priorityHeap) Push(x any) { *h = append(*h, x.(priorityEntry)) }
+
+func (h *priorityHeap) Pop() any {
+	old := *h
+	e := old[len(old)-1]
+	*h = old[:len(old)-1]
+	return e
+}
+
+// defaultScore prefers shallow pages whose URL or link text mention the configured patterns and search terms.
+func (c *Crawler) defaultScore(pageURL, linkText string, depth int) float64 {
+	score := -float64(depth)
+	target := strings.ToLower(pageURL + " " + linkText)
+	terms := [][]string{c.Selectors.UrlPatterns, c.Selectors.LinkTextPatterns, c.Selectors.ContentPatterns, c.SearchAny, c.SearchAll}
+	for _, list := range terms {
+		for _, term := range list {
+			if term != "" && strings.Contains(target, strings.ToLower(term)) {
+				score++
+			}
+		}
+	}
+	return score
+}
@@ -0,0 +1,117 @@
+package crawler
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestFrontierOrder(t *testing.T) {
+	entries := []Entry{
+		{URL: "a", Depth: 1, Priority: 1},
+		{URL: "b", Depth: 2, Priority: 3},
+		{URL: "c", Depth: 2, Priority: 3},
+		{URL: "d", Depth: 1, Priority: 3},
+	}
+	tests := map[string][]string{
+		"bfs":      {"a", "b", "c", "d"},
+		"dfs":      {"d", "c", "b", "a"},
+		"priority": {"d", "b", "c", "a"},
+	}
+	for order, want := range tests {
+		t.Run(order, func(t *testing.T) {
+			f, err := NewFrontier(order)
+			assert.NoError(t, err)
+			for _, e := range entries {
+				f.Push(e)
+			}
+			assert.Equal(t, len(entries), f.Len())
+			got := []string{}
+			for {
+				e, ok := f.Pop()
+				if !ok {
+					break
+				}
+				got = append(got, e.URL)
+			}
+			assert.Equal(t, want, got)
+		})
+	}
+	_, err := NewFrontier("random")
+	assert.Error(t, err)
+}
+
+// treeSite serves pages where every page links to three children, e.g. /1 links to /1/1, /1/2 and /1/3.
+func treeSite() *httptest.Server {
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		path := strings.TrimSuffix(r.URL.Path, "/")
+		links := ""
+		for i := 1; i <= 3; i++ {
+			links += fmt.Sprintf(`<a href="%s/%d">child %d</a>`, path, i, i)
+		}
+		_, _ = w.Write([]byte("<html><body>" + links + "</body></html>"))
+	}))
+}
+
+func TestCrawlMaxLinksKeepsShallowestPages(t *testing.T) {
+	server := treeSite()
+	defer server.Close()
+
+	c := NewCrawler()
+	c.Silent = true
+	c.Threads = 8
+	c.MaxDepth = 4
+	c.MaxLinks = 4
+	_, err := c.Crawl(server.URL)
+	assert.NoError(t, err)
+	for _, r := range c.Results() {
+		assert.LessOrEqual(t, r.Depth, 2, r.URL)
+	}
+	assert.Len(t, c.Results(), 4)
+}
+
+func TestCrawlDepthFirst(t *testing.T) {
+	server := treeSite()
+	defer server.Close()
+
+	c := NewCrawler()
+	c.Silent = true
+	c.Order = "dfs"
+	c.MaxDepth = 4
+	c.MaxLinks = 4
+	_, err := c.Crawl(server.URL)
+	assert.NoError(t, err)
+	got := []string{}
+	for _, r := range c.Results() {
+		got = append(got, strings.TrimPrefix(r.URL, server.URL))
+	}
+	assert.Equal(t, []string{"/", "/3", "/3/3", "/3/3/3"}, got)
+}
+
+func TestCrawlPriorityOrder(t *testing.T) {
+	server := treeSite()
+	defer server.Close()
+
+	c := NewCrawler()
+	c.Silent = true
+	c.Order = "priority"
+	c.MaxDepth = 3
+	c.MaxLinks = 3
+	c.Score = func(pageURL, linkText string, depth int) float64 {
+		if strings.HasSuffix(pageURL, "/2") {
+			return 10
+		}
+		return -float64(depth)
+	}
+	_, err := c.Crawl(server.URL)
+	assert.NoError(t, err)
+	got := []string{}
+	for _, r := range c.Results() {
+		got = append(got, strings.TrimPrefix(r.URL, server.URL))
+	}
+	assert.Equal(t, []string{"/", "/2", "/2/2"}, got)
+}
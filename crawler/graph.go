@@ -0,0 +1,362 @@
+package crawler
+
+import (
+	"encoding/json"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"maps"
+	"math"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+const (
+	// pageRankDamping is the chance a surfer follows a link rather than jumping to a random page.
+	pageRankDamping = 0.85
+	// pageRankIterations and pageRankTolerance bound the power iteration.
+	pageRankIterations = 100
+	pageRankTolerance  = 1e-9
+)
+
+// Edge is a link from one page to another.
+type Edge struct {
+	Source   string `json:"source"`
+	Target   string `json:"target"`
+	Text     string `json:"text,omitempty"`
+	Rel      string `json:"rel,omitempty"`
+	Nofollow bool   `json:"nofollow,omitempty"`
+}
+
+// LinkGraph is the link structure found by a crawl.
+type LinkGraph struct {
+	Pages []string // pages that were crawled, sorted
+	Nodes []string // crawled pages and every link target, sorted
+	Edges []Edge
+}
+
+// recordEdges adds the links of a page to the link graph.
+func (c *Crawler) recordEdges(pageURL string, anchors []anchor) {
+	if !c.RecordGraph {
+		return
+	}
+	edges := make([]Edge, 0, len(anchors))
+	for _, a := range anchors {
+		target := c.normalize(toAbsoluteURL(pageURL, a.href))
+		if !strings.HasPrefix(target, "http://") && !strings.HasPrefix(target, "https://") {
+			continue
+		}
+		rel := strings.Join(strings.Fields(strings.ToLower(a.rel)), " ")
+		edges = append(edges, Edge{
+			Source:   pageURL,
+			Target:   target,
+			Text:     a.text,
+			Rel:      rel,
+			Nofollow: slices.Contains(strings.Fields(rel), "nofollow"),
+		})
+	}
+	c.setEdges(pageURL, edges)
+}
+
+// setEdges stores the links of a page, replacing the ones stored before.
+func (c *Crawler) setEdges(pageURL string, edges []Edge) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	if c.edges == nil {
+		c.edges = make(map[string][]Edge)
+	}
+	c.edges[pageURL] = edges
+}
+
+// pageEdges returns the stored links of a page.
+func (c *Crawler) pageEdges(pageURL string) []Edge {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	return slices.Clone(c.edges[pageURL])
+}
+
+// Graph returns the link graph recorded by the last crawl when RecordGraph is set.
+func (c *Crawler) Graph() LinkGraph {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	g := LinkGraph{Pages: []string{}, Edges: []Edge{}}
+	for _, edges := range c.edges {
+		g.Edges = append(g.Edges, edges...)
+	}
+	nodes := make(map[string]bool)
+	for url, r := range c.pageResults {
+		if r.Error == "" {
+			g.Pages = append(g.Pages, url)
+			nodes[url] = true
+		}
+	}
+	for _, e := range g.Edges {
+		nodes[e.Source] = true
+		nodes[e.Target] = true
+	}
+	slices.Sort(g.Pages)
+	g.Nodes = slices.Sorted(maps.Keys(nodes))
+	slices.SortStableFunc(g.Edges, func(a, b Edge) int {
+		if n := strings.Compare(a.Source, b.Source); n != 0 {
+			return n
+		}
+		return strings.Compare(a.Target, b.Target)
+	})
+	return g
+}
+
+// InDegree returns the number of distinct pages linking to each node, ignoring links from a page to itself.
+func (g LinkGraph) InDegree() map[string]int {
+	degree := make(map[string]int, len(g.Nodes))
+	for _, node := range g.Nodes {
+		degree[node] = 0
+	}
+	for source, targets := range g.adjacency(false) {
+		for _, target := range targets {
+			if target != source {
+				degree[target]++
+			}
+		}
+	}
+	return degree
+}
+
+// Orphans returns the crawled pages no other crawled page links to, such as
+// pages only reached through seeds, sitemaps or feeds.
+func (g LinkGraph) Orphans() []string {
+	degree := g.InDegree()
+	orphans := []string{}
+	for _, page := range g.Pages {
+		if degree[page] == 0 {
+			orphans = append(orphans, page)
+		}
+	}
+	return orphans
+}
+
+// PageRank ranks the nodes by the links between them, nofollow links are ignored.
+// Scores add up to 1, pages without links share their score with every page.
+func (g LinkGraph) PageRank() map[string]float64 {
+	n := len(g.Nodes)
+	rank := make(map[string]float64, n)
+	if n == 0 {
+		return rank
+	}
+	for _, node := range g.Nodes {
+		rank[node] = 1 / float64(n)
+	}
+	adjacency := g.adjacency(true)
+	for range pageRankIterations {
+		next := make(map[string]float64, n)
+		dangling := 0.0
+		for _, node := range g.Nodes {
+			targets := adjacency[node]
+			if len(targets) == 0 {
+				dangling += rank[node]
+				continue
+			}
+			share := rank[node] / float64(len(targets))
+			for _, target := range targets {
+				next[target] += share
+			}
+		}
+		change := 0.0
+		for _, node := range g.Nodes {
+			score := (1-pageRankDamping)/float64(n) + pageRankDamping*(next[node]+dangling/float64(n))
+			change += math.Abs(score - rank[node])
+			next[node] = score
+		}
+		rank = next
+		if change < pageRankTolerance {
+			break
+		}
+	}
+	return rank
+}
+
+// adjacency returns the distinct targets of each source, optionally skipping nofollow links.
+func (g LinkGraph) adjacency(follow bool) map[string][]string {
+	adjacency := make(map[string][]string)
+	for _, e := range g.Edges {
+		if follow && (e.Nofollow || e.Source == e.Target) {
+			continue
+		}
+		if !slices.Contains(adjacency[e.Source], e.Target) {
+			adjacency[e.Source] = append(adjacency[e.Source], e.Target)
+		}
+	}
+	return adjacency
+}
+
+// WriteDOT writes the graph in Graphviz DOT format, nofollow links are dashed.
+func (g LinkGraph) WriteDOT(w io.Writer) error {
+	var sb strings.Builder
+	crawled := make(map[string]bool, len(g.Pages))
+	for _, page := range g.Pages {
+		crawled[page] = true
+	}
+	rank := g.PageRank()
+	sb.WriteString("digraph links {\n")
+	for _, node := range g.Nodes {
+		attrs := fmt.Sprintf("pagerank=%s", strconv.FormatFloat(rank[node], 'g', 6, 64))
+		if !crawled[node] {
+			attrs += ", style=dashed"
+		}
+		fmt.Fprintf(&sb, "  %s [%s];\n", dotQuote(node), attrs)
+	}
+	for _, e := range g.Edges {
+		attrs := []string{}
+		if e.Text != "" {
+			attrs = append(attrs, "label="+dotQuote(e.Text))
+		}
+		if e.Rel != "" {
+			attrs = append(attrs, "rel="+dotQuote(e.Rel))
+		}
+		if e.Nofollow {
+			attrs = append(attrs, "style=dashed")
+		}
+		fmt.Fprintf(&sb, "  %s -> %s", dotQuote(e.Source), dotQuote(e.Target))
+		if len(attrs) > 0 {
+			fmt.Fprintf(&sb, " [%s]", strings.Join(attrs, ", "))
+		}
+		sb.WriteString(";\n")
+	}
+	sb.WriteString("}\n")
+	_, err := io.WriteString(w, sb.String())
+	return err
+}
+
+// dotQuote quotes a DOT identifier.
+func dotQuote(s string) string {
+	s = strings.ReplaceAll(s, `\`, `\\`)
+	s = strings.ReplaceAll(s, `"`, `\"`)
+	s = strings.ReplaceAll(s, "\n", `\n`)
+	return `"` + s + `"`
+}
+
+type graphmlXML struct {
+	XMLName xml.Name        `xml:"graphml"`
+	Xmlns   string          `xml:"xmlns,attr"`
+	Keys    []graphmlKey    `xml:"key"`
+	Graph   graphmlGraphXML `xml:"graph"`
+}
+
+type graphmlKey struct {
+	ID   string `xml:"id,attr"`
+	For  string `xml:"for,attr"`
+	Name string `xml:"attr.name,attr"`
+	Type string `xml:"attr.type,attr"`
+}
+
+type graphmlGraphXML struct {
+	EdgeDefault string        `xml:"edgedefault,attr"`
+	Nodes       []graphmlNode `xml:"node"`
+	Edges       []graphmlEdge `xml:"edge"`
+}
+
+type graphmlNode struct {
+	ID   string        `xml:"id,attr"`
+	Data []graphmlData `xml:"data"`
+}
+
+type graphmlEdge struct {
+	Source string        `xml:"source,attr"`
+	Target string        `xml:"target,attr"`
+	Data   []graphmlData `xml:"data"`
+}
+
+type graphmlData struct {
+	Key   string `xml:"key,attr"`
+	Value string `xml:",chardata"`
+}
+
+// WriteGraphML writes the graph as GraphML with crawled, in-degree and pagerank on nodes
+// and text, rel and nofollow on edges.
+func (g LinkGraph) WriteGraphML(w io.Writer) error {
+	crawled := make(map[string]bool, len(g.Pages))
+	for _, page := range g.Pages {
+		crawled[page] = true
+	}
+	degree := g.InDegree()
+	rank := g.PageRank()
+	doc := graphmlXML{
+		Xmlns: "http://graphml.graphdrawing.org/xmlns",
+		Keys: []graphmlKey{
+			{ID: "crawled", For: "node", Name: "crawled", Type: "boolean"},
+			{ID: "in_degree", For: "node", Name: "in_degree", Type: "int"},
+			{ID: "pagerank", For: "node", Name: "pagerank", Type: "double"},
+			{ID: "text", For: "edge", Name: "text", Type: "string"},
+			{ID: "rel", For: "edge", Name: "rel", Type: "string"},
+			{ID: "nofollow", For: "edge", Name: "nofollow", Type: "boolean"},
+		},
+		Graph: graphmlGraphXML{EdgeDefault: "directed"},
+	}
+	for _, node := range g.Nodes {
+		doc.Graph.Nodes = append(doc.Graph.Nodes, graphmlNode{ID: node, Data: []graphmlData{
+			{Key: "crawled", Value: strconv.FormatBool(crawled[node])},
+			{Key: "in_degree", Value: strconv.Itoa(degree[node])},
+			{Key: "pagerank", Value: strconv.FormatFloat(rank[node], 'g', 6, 64)},
+		}})
+	}
+	for _, e := range g.Edges {
+		edge := graphmlEdge{Source: e.Source, Target: e.Target}
+		if e.Text != "" {
+			edge.Data = append(edge.Data, graphmlData{Key: "text", Value: e.Text})
+		}
+		if e.Rel != "" {
+			edge.Data = append(edge.Data, graphmlData{Key: "rel", Value: e.Rel})
+		}
+		edge.Data = append(edge.Data, graphmlData{Key: "nofollow", Value: strconv.FormatBool(e.Nofollow)})
+		doc.Graph.Edges = append(doc.Graph.Edges, edge)
+	}
+	data, err := xml.MarshalIndent(doc, "", "  ")
+	if err != nil {
+		return err
+	}
+	_, err = io.WriteString(w, xml.Header+string(data)+"\n")
+	return err
+}
+
+// graphNode is a node in the JSON adjacency export.
+type graphNode struct {
+	URL       string  `json:"url"`
+	Crawled   bool    `json:"crawled"`
+	InDegree  int     `json:"in_degree"`
+	OutDegree int     `json:"out_degree"`
+	PageRank  float64 `json:"pagerank"`
+	Links     []Edge  `json:"links,omitempty"`
+}
+
+// WriteJSON writes the graph as a JSON adjacency list: every node with its
+// metrics and outgoing links, followed by the orphan pages.
+func (g LinkGraph) WriteJSON(w io.Writer) error {
+	crawled := make(map[string]bool, len(g.Pages))
+	for _, page := range g.Pages {
+		crawled[page] = true
+	}
+	degree := g.InDegree()
+	rank := g.PageRank()
+	outgoing := make(map[string][]Edge)
+	for _, e := range g.Edges {
+		outgoing[e.Source] = append(outgoing[e.Source], e)
+	}
+	adjacency := g.adjacency(false)
+	nodes := make([]graphNode, 0, len(g.Nodes))
+	for _, node := range g.Nodes {
+		nodes = append(nodes, graphNode{
+			URL:       node,
+			Crawled:   crawled[node],
+			InDegree:  degree[node],
+			OutDegree: len(adjacency[node]),
+			PageRank:  rank[node],
+			Links:     outgoing[node],
+		})
+	}
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(struct {
+		Nodes   []graphNode `json:"nodes"`
+		Orphans []string    `json:"orphans"`
+	}{nodes, g.Orphans()})
+}
@@ -0,0 +1,138 @@
+package crawler
+
+import (
+	"bytes"
+	"encoding/json"
+	"encoding/xml"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func graphSite() *httptest.Server {
+	pages := map[string]string{
+		"/":      `<a href="/a">A page</a><a href="/b">B page</a><a href="https://ads.example/x" rel="NoFollow sponsored">ad</a>`,
+		"/a":     `<a href="/">home</a><a href="/b">b</a><a href="/a">self</a>`,
+		"/b":     `<a href="/">home</a>`,
+		"/alone": `<p>nothing links here</p>`,
+	}
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, ok := pages[r.URL.Path]
+		if !ok {
+			http.NotFound(w, r)
+			return
+		}
+		etag := `"` + r.URL.Path + `"`
+		w.Header().Set("ETag", etag)
+		if r.Header.Get("If-None-Match") == etag {
+			w.WriteHeader(http.StatusNotModified)
+			return
+		}
+		_, _ = w.Write([]byte(body))
+	}))
+}
+
+func TestGraph(t *testing.T) {
+	server := graphSite()
+	defer server.Close()
+	c := NewCrawler()
+	c.Silent = true
+	c.MaxDepth = 3
+	c.RecordGraph = true
+	c.Selectors.Domains = []string{getDomain(server.URL)}
+	_, err := c.Crawl(server.URL, server.URL+"/alone")
+	assert.NoError(t, err)
+
+	home, a, b, alone, ad := server.URL+"/", server.URL+"/a", server.URL+"/b", server.URL+"/alone", "https://ads.example/x"
+	g := c.Graph()
+	assert.Equal(t, []string{home, a, alone, b}, g.Pages)
+	assert.Equal(t, []string{home, a, alone, b, ad}, g.Nodes)
+	assert.Contains(t, g.Edges, Edge{Source: home, Target: ad, Text: "ad", Rel: "nofollow sponsored", Nofollow: true})
+	assert.Contains(t, g.Edges, Edge{Source: a, Target: b, Text: "b"})
+	assert.Len(t, g.Edges, 7)
+
+	assert.Equal(t, map[string]int{home: 2, a: 1, b: 2, alone: 0, ad: 1}, g.InDegree())
+	assert.Equal(t, []string{alone}, g.Orphans())
+
+	rank := g.PageRank()
+	total := 0.0
+	for _, score := range rank {
+		total += score
+	}
+	assert.InDelta(t, 1.0, total, 1e-6)
+	assert.Greater(t, rank[home], rank[a])
+	assert.Greater(t, rank[b], rank[a])
+	assert.Greater(t, rank[a], rank[ad], "nofollow links pass no rank")
+
+	var dot bytes.Buffer
+	assert.NoError(t, g.WriteDOT(&dot))
+	assert.True(t, strings.HasPrefix(dot.String(), "digraph links {\n"))
+	assert.Contains(t, dot.String(), `  "`+home+`" -> "`+ad+`" [label="ad", rel="nofollow sponsored", style=dashed];`)
+	assert.Contains(t, dot.String(), `  "`+ad+`" [pagerank=`)
+
+	var graphml bytes.Buffer
+	assert.NoError(t, g.WriteGraphML(&graphml))
+	var parsed graphmlXML
+	assert.NoError(t, xml.Unmarshal(graphml.Bytes(), &parsed))
+	assert.Len(t, parsed.Graph.Nodes, 5)
+	assert.Len(t, parsed.Graph.Edges, 7)
+
+	var adjacency bytes.Buffer
+	assert.NoError(t, g.WriteJSON(&adjacency))
+	var decoded struct {
+		Nodes   []graphNode `json:"nodes"`
+		Orphans []string    `json:"orphans"`
+	}
+	assert.NoError(t, json.Unmarshal(adjacency.Bytes(), &decoded))
+	assert.Len(t, decoded.Nodes, 5)
+	assert.Equal(t, []string{alone}, decoded.Orphans)
+	assert.Equal(t, a, decoded.Nodes[1].URL)
+	assert.Equal(t, 3, decoded.Nodes[1].OutDegree)
+	assert.Len(t, decoded.Nodes[1].Links, 3)
+}
+
+func TestGraphResumedAndUnchanged(t *testing.T) {
+	server := graphSite()
+	defer server.Close()
+	crawl := func(set func(c *Crawler)) *Crawler {
+		c := NewCrawler()
+		c.Silent = true
+		c.MaxDepth = 3
+		c.RecordGraph = true
+		c.Selectors.Domains = []string{getDomain(server.URL)}
+		set(c)
+		_, err := c.Crawl(server.URL, server.URL+"/alone")
+		assert.NoError(t, err)
+		return c
+	}
+	want := crawl(func(c *Crawler) {}).Graph()
+
+	// pages finished before a restart keep their links
+	dir := t.TempDir()
+	crawl(func(c *Crawler) {
+		c.StateDir = dir
+		c.MaxLinks = 2
+	})
+	resumed := crawl(func(c *Crawler) {
+		c.StateDir = dir
+		c.Resume = true
+	})
+	assert.Equal(t, want, resumed.Graph())
+
+	// and so do pages the server reports as not modified
+	file := filepath.Join(t.TempDir(), "validators.json")
+	crawl(func(c *Crawler) { c.ValidatorsFile = file })
+	unchanged := crawl(func(c *Crawler) {
+		c.ValidatorsFile = file
+		c.OnlyChanged = true
+	})
+	for _, r := range unchanged.Results() {
+		assert.Equal(t, http.StatusNotModified, r.StatusCode, r.URL)
+	}
+	assert.Equal(t, want, unchanged.Graph())
+	assert.Equal(t, []string{server.URL + "/alone"}, unchanged.Graph().Orphans())
+}
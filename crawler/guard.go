@@ -0,0 +1,134 @@
+package crawler
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"slices"
+	"strings"
+)
+
+// sniffLength is how much of a body is used to detect its content type when the server sends none.
+const sniffLength = 512
+
+// skippedError is returned for pages that are not parsed because of their content type or
+// size, or because they redirect to a url that isn't crawled.
+type skippedError struct {
+	reason string
+}
+
+func (e *skippedError) Error() string {
+	return "skipped: " + e.reason
+}
+
+// skipReason returns why a fetch error skipped the page, or "" for other errors.
+func skipReason(err error) string {
+	var skipped *skippedError
+	if errors.As(err, &skipped) {
+		return skipped.reason
+	}
+	return ""
+}
+
+// skip records a page that was left out and why.
+func (c *Crawler) skip(pageURL string, depth int, reason string) {
+	if !c.Silent {
+		fmt.Printf("skipping %s: %s\n", pageURL, reason)
+	}
+	c.recordPage(pageURL, func(r *PageResult) {
+		r.Depth = depth
+		r.Skipped = reason
+	})
+}
+
+// probe asks for the headers of a page with HEAD when ProbeHead is set, so pages that
+// would be skipped are never downloaded. Servers that don't answer HEAD are let through.
+func (c *Crawler) probe(pageURL string) error {
+	if !c.ProbeHead {
+		return nil
+	}
+	req, err := http.NewRequest(http.MethodHead, c.fetchURL(pageURL), nil)
+	if err != nil {
+		return nil
+	}
+	resp, err := c.do(req)
+	if err != nil {
+		return nil
+	}
+	_ = resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil
+	}
+	return c.checkHeaders(pageURL, resp.Header, resp.ContentLength)
+}
+
+// checkHeaders skips responses with a content type that isn't parsed, or a length over MaxBodySize.
+func (c *Crawler) checkHeaders(pageURL string, header http.Header, length int64) error {
+	if contentType := header.Get("Content-Type"); contentType != "" {
+		c.recordPage(pageURL, func(r *PageResult) {
+			r.ContentType = contentType
+		})
+		if !c.allowedType(contentType) {
+			return &skippedError{reason: "content type " + mediaType(contentType)}
+		}
+	}
+	if c.MaxBodySize > 0 && length > c.MaxBodySize {
+		return &skippedError{reason: fmt.Sprintf("body of %d bytes is larger than %d", length, c.MaxBodySize)}
+	}
+	return nil
+}
+
+// readBody reads a page, giving up once it grows past MaxBodySize. Pages without a
+// Content-Type are checked against ContentTypes by their first bytes.
+func (c *Crawler) readBody(pageURL string, resp *http.Response) ([]byte, error) {
+	var body io.Reader = resp.Body
+	if c.MaxBodySize > 0 {
+		body = io.LimitReader(resp.Body, c.MaxBodySize+1)
+	}
+	if resp.Header.Get("Content-Type") == "" {
+		head := make([]byte, sniffLength)
+		n, err := io.ReadFull(body, head)
+		if err != nil && !errors.Is(err, io.ErrUnexpectedEOF) && !errors.Is(err, io.EOF) {
+			return nil, err
+		}
+		head = head[:n]
+		contentType := http.DetectContentType(head)
+		c.recordPage(pageURL, func(r *PageResult) {
+			r.ContentType = contentType
+		})
+		if !c.allowedType(contentType) {
+			return nil, &skippedError{reason: "content type " + mediaType(contentType)}
+		}
+		body = io.MultiReader(strings.NewReader(string(head)), body)
+	}
+	data, err := io.ReadAll(body)
+	if err != nil {
+		return nil, err
+	}
+	if c.MaxBodySize > 0 && int64(len(data)) > c.MaxBodySize {
+		return nil, &skippedError{reason: fmt.Sprintf("body is larger than %d bytes", c.MaxBodySize)}
+	}
+	return data, nil
+}
+
+// allowedType reports whether pages of a content type are parsed, all are when ContentTypes is empty.
+func (c *Crawler) allowedType(contentType string) bool {
+	if len(c.ContentTypes) == 0 {
+		return true
+	}
+	media := mediaType(contentType)
+	return slices.ContainsFunc(c.ContentTypes, func(allowed string) bool {
+		return strings.EqualFold(strings.TrimSpace(allowed), media)
+	})
+}
+
+// mediaType returns the media type of a Content-Type header without its parameters.
+func mediaType(contentType string) string {
+	media, _, err := mime.ParseMediaType(contentType)
+	if err != nil {
+		media, _, _ = strings.Cut(contentType, ";")
+	}
+	return strings.ToLower(strings.TrimSpace(media))
+}
@@ -0,0 +1,117 @@
+package crawler
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestContentGuards(t *testing.T) {
+	var mutex sync.Mutex
+	downloads := map[string]int{}
+	big := strings.Repeat("x", 2048)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method == http.MethodGet {
+			mutex.Lock()
+			downloads[r.URL.Path]++
+			mutex.Unlock()
+		}
+		switch r.URL.Path {
+		case "/":
+			w.Header().Set("Content-Type", "text/html; charset=utf-8")
+			_, _ = w.Write([]byte(`<a href="/manual.pdf">pdf</a><a href="/page.xhtml">xhtml</a>
+				<a href="/big">big</a><a href="/streamed">streamed</a><a href="/untyped">untyped</a>`))
+		case "/manual.pdf":
+			w.Header().Set("Content-Type", "application/pdf")
+			_, _ = w.Write([]byte("%PDF-1.4"))
+		case "/page.xhtml":
+			w.Header().Set("Content-Type", "application/xhtml+xml")
+			_, _ = w.Write([]byte(`<html><body>xhtml</body></html>`))
+		case "/big":
+			w.Header().Set("Content-Type", "text/html")
+			_, _ = w.Write([]byte(big))
+		case "/streamed":
+			// no Content-Length, the size is only known while reading
+			w.Header().Set("Content-Type", "text/html")
+			for range 4 {
+				_, _ = w.Write([]byte(big[:512]))
+				w.(http.Flusher).Flush()
+			}
+			_, _ = w.Write([]byte("!"))
+		case "/untyped":
+			w.Header()["Content-Type"] = nil // stop the server from sniffing
+			_, _ = w.Write([]byte{0x1f, 0x8b, 0x08, 0x00})
+		}
+	}))
+	defer server.Close()
+
+	tests := []struct {
+		name      string
+		probe     bool
+		wantPDFs  int
+		wantSkips map[string]string
+	}{
+		{"early abort", false, 1, map[string]string{
+			"/manual.pdf": "content type application/pdf",
+			"/big":        "body of 2048 bytes is larger than 2000",
+			"/streamed":   "body is larger than 2000 bytes",
+			"/untyped":    "content type application/x-gzip",
+		}},
+		{"head probe", true, 0, map[string]string{
+			"/manual.pdf": "content type application/pdf",
+			"/big":        "body of 2048 bytes is larger than 2000",
+			"/streamed":   "body is larger than 2000 bytes",
+			"/untyped":    "content type application/x-gzip",
+		}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mutex.Lock()
+			clear(downloads)
+			mutex.Unlock()
+			c := NewCrawler()
+			c.Silent = true
+			c.MaxBodySize = 2000
+			c.ProbeHead = tt.probe
+			_, err := c.Crawl(server.URL + "/")
+			assert.NoError(t, err)
+			skips := map[string]string{}
+			for _, r := range c.Results() {
+				assert.Empty(t, r.Error, r.URL)
+				if r.Skipped != "" {
+					skips[strings.TrimPrefix(r.URL, server.URL)] = r.Skipped
+				}
+			}
+			assert.Equal(t, tt.wantSkips, skips)
+			mutex.Lock()
+			assert.Equal(t, tt.wantPDFs, downloads["/manual.pdf"])
+			mutex.Unlock()
+		})
+	}
+}
+
+func TestAllowedType(t *testing.T) {
+	c := NewCrawler()
+	tests := []struct {
+		contentType string
+		want        bool
+	}{
+		{"text/html", true},
+		{"TEXT/HTML; charset=ISO-8859-1", true},
+		{"application/xhtml+xml", true},
+		{"text/plain; charset=utf-8", false},
+		{"application/pdf", false},
+		{"text/html;;", true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.contentType, func(t *testing.T) {
+			assert.Equal(t, tt.want, c.allowedType(tt.contentType))
+		})
+	}
+	c.ContentTypes = nil
+	assert.True(t, c.allowedType("application/pdf"))
+}
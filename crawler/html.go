@@ -3,10 +3,10 @@ package crawler
 import (
 	"bytes"
 	"fmt"
-	"io"
 	"net/http"
 	"slices"
 	"strings"
+	"time"
 
 	"golang.org/x/net/html"
 
@@ -23,20 +23,155 @@ func (c *Crawler) FetchHTML(pageURL string, javascriptEnabled bool) (string, err
 	case "collect":
 		// nothing yet
 	}
+	if err := c.probe(pageURL); err != nil {
+		return "", err
+	}
 	if javascriptEnabled {
-		html, err := browser.GetHtmlContent(pageURL)
-		if err != nil {
-			// Browser errors are returned to caller for handling
-			// Caller will decide if it's transient or critical
-			return html, err
-		}
-		return html, nil
+		return c.renderPage(pageURL)
+	}
+	if c.JsAuto {
+		return c.fetchAuto(pageURL)
 	}
 	return c.requestPage(pageURL)
 }
 
+// renderPage retrieves the HTML content of the given URL using the browser.
+func (c *Crawler) renderPage(pageURL string) (string, error) {
+	page, err := c.getBrowser().Render(c.fetchURL(pageURL))
+	if err != nil {
+		// Browser errors are returned to caller for handling
+		// Caller will decide if it's transient or critical
+		return "", err
+	}
+	c.recordPage(pageURL, func(r *PageResult) {
+		r.Javascript = true
+		r.Screenshot = page.Screenshot
+		r.PDF = page.PDF
+		r.Blocked = page.Blocked
+		if page.CaptureErr != nil {
+			r.CaptureError = page.CaptureErr.Error()
+		}
+	})
+	if page.URL != "" {
+		c.recordRedirects(pageURL, page.URL, nil)
+		if err := c.redirected(pageURL, page.URL); err != nil {
+			return "", err
+		}
+	}
+	c.validate(pageURL, nil, page.HTML)
+	if c.mode == "collect" {
+		c.collectBlocked(pageURL, page.Blocked)
+	}
+	return page.HTML, nil
+}
+
+// getBrowser returns the shared browser, creating it on first use.
+func (c *Crawler) getBrowser() *browser.Browser {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	if c.browser == nil {
+		c.browser = browser.New(browser.Options{
+			Timeout:        time.Duration(c.Timeout) * time.Second,
+			Pages:          c.Threads,
+			ViewportWidth:  c.Captures.ViewportWidth,
+			ViewportHeight: c.Captures.ViewportHeight,
+			Screenshot:     c.Captures.Screenshot,
+			PDF:            c.Captures.PDF,
+			OutputDir:      c.Captures.OutputDir,
+			RemoteURL:      c.BrowserWSURL,
+			BlockResources: c.BlockResources,
+			BlockURLs:      c.BlockURLs,
+			UserAgents:     c.UserAgents,
+			Headers:        c.Headers,
+			Jar:            c.cookieJar(),
+			Authorization:  c.pageAuthorization,
+			ProxyPAC:       c.proxies.pacScript(),
+		})
+	}
+	return c.browser
+}
+
+// closeBrowser shuts down the shared browser if one was started.
+func (c *Crawler) closeBrowser() {
+	c.mutex.Lock()
+	b := c.browser
+	c.browser = nil
+	c.mutex.Unlock()
+	if b != nil {
+		b.Close()
+	}
+}
+
+// do sends an HTTP request for the crawler with its headers, credentials, user agent
+// and cookies, through its cache, cassettes and proxies, giving up after Timeout seconds.
+func (c *Crawler) do(req *http.Request) (*http.Response, error) {
+	return c.send(req, nil)
+}
+
+// send is do with a check run before each redirect is followed, after the MaxRedirects limit.
+func (c *Crawler) send(req *http.Request, follow func(next *http.Request) error) (*http.Response, error) {
+	for name, value := range c.Headers {
+		req.Header.Set(name, value)
+	}
+	if auth := c.authorization(req.URL); auth != "" {
+		req.Header.Set("Authorization", auth)
+	}
+	if len(c.UserAgents) > 0 {
+		n := c.requests.Add(1) - 1
+		req.Header.Set("User-Agent", c.UserAgents[n%uint64(len(c.UserAgents))])
+	}
+	client := &http.Client{
+		Timeout: time.Duration(c.Timeout) * time.Second,
+		Jar:     c.cookieJar(),
+		CheckRedirect: func(next *http.Request, via []*http.Request) error {
+			if len(via) > c.MaxRedirects {
+				return fmt.Errorf("stopped after %d redirects", c.MaxRedirects)
+			}
+			if follow != nil {
+				return follow(next)
+			}
+			return nil
+		},
+	}
+	if err := c.openCache(); err != nil {
+		return nil, err
+	}
+	if err := c.openTape(); err != nil {
+		return nil, err
+	}
+	pool, err := c.proxyPool()
+	if err != nil {
+		return nil, err
+	}
+	if pool != nil {
+		return c.sendProxied(client, pool, req)
+	}
+	client.Transport = c.transport(http.DefaultTransport)
+	return client.Do(req)
+}
+
+// cookieJar returns Jar as an http.CookieJar, nil when cookies are disabled.
+func (c *Crawler) cookieJar() http.CookieJar {
+	if c.Jar == nil {
+		return nil
+	}
+	return c.Jar
+}
+
 func (c *Crawler) requestPage(pageURL string) (string, error) {
-	resp, err := http.Get(pageURL)
+	req, err := http.NewRequest(http.MethodGet, c.fetchURL(pageURL), nil)
+	if err != nil {
+		return "", fmt.Errorf("invalid request for %s: %w", pageURL, err)
+	}
+	c.conditional(req, pageURL)
+	resp, err := c.send(req, c.followRedirect(pageURL))
+	if resp != nil {
+		chain, finalURL := redirectChain(resp)
+		c.recordRedirects(pageURL, finalURL, chain)
+	}
+	if skipReason(err) != "" {
+		return "", err
+	}
 	if err != nil {
 		// Network errors are transient - return for caller to handle
 		return "", fmt.Errorf("network error fetching %s: %w", pageURL, err)
@@ -44,15 +179,40 @@ func (c *Crawler) requestPage(pageURL string) (string, error) {
 	defer func() {
 		_ = resp.Body.Close() // Ignore close errors on HTTP response body
 	}()
+	c.recordPage(pageURL, func(r *PageResult) {
+		r.StatusCode = resp.StatusCode
+	})
+	if resp.StatusCode == http.StatusNotModified && c.validators != nil {
+		return "", errNotModified
+	}
+	if resp.StatusCode == http.StatusNotFound || resp.StatusCode == http.StatusGone {
+		c.markGone(pageURL)
+	}
 	if resp.StatusCode != http.StatusOK {
 		// HTTP errors (403, 404, 500, etc.) are transient in scraping context
 		return "", fmt.Errorf("HTTP %d %s for %s", resp.StatusCode, resp.Status, pageURL)
 	}
-	bodyBytes, err := io.ReadAll(resp.Body)
+	c.headerDirectives(pageURL, resp.Header)
+	if err := c.checkHeaders(pageURL, resp.Header, resp.ContentLength); err != nil {
+		return "", err
+	}
+	bodyBytes, err := c.readBody(pageURL, resp)
 	if err != nil {
 		return "", err
 	}
-	htmlString := string(bodyBytes)
+	htmlString, charsetName, err := decodeHTML(bodyBytes, resp.Header.Get("Content-Type"))
+	if err != nil {
+		return "", fmt.Errorf("%w for %s", err, pageURL)
+	}
+	c.recordPage(pageURL, func(r *PageResult) {
+		r.Charset = charsetName
+	})
+	if lastModified := resp.Header.Get("Last-Modified"); lastModified != "" {
+		c.recordPage(pageURL, func(r *PageResult) {
+			r.LastModified = lastModified
+		})
+	}
+	c.validate(pageURL, resp.Header, htmlString)
 	if len(c.SearchAny) > 0 {
 		for _, s := range c.SearchAny {
 			results := simpleSearch(s, htmlString, 30)
@@ -104,11 +264,28 @@ func (c *Crawler) containsSelectors(n *html.Node) bool {
 
 // extractLinks extracts links within the specified element by id or class from the HTML content.
 func (c *Crawler) extractLinks(htmlContent string) (map[string]string, error) {
+	anchors, err := c.extractAnchors(htmlContent)
+	if err != nil {
+		return nil, err
+	}
+	return linkMap(anchors), nil
+}
+
+// anchor is a link on a page with its text and rel attribute.
+type anchor struct {
+	href   string
+	text   string
+	rel    string
+	source string // the entry of LinkSources it was found in, "" for <a href>
+}
+
+// extractAnchors extracts the links within the specified element by id or class, in document order.
+func (c *Crawler) extractAnchors(htmlContent string) ([]anchor, error) {
 	doc, err := html.Parse(strings.NewReader(htmlContent))
 	if err != nil {
 		return nil, err
 	}
-	links := make(map[string]string)
+	anchors := []anchor{}
 	var f func(*html.Node)
 	inTargetElement := false
 	f = func(n *html.Node) {
@@ -118,18 +295,28 @@ func (c *Crawler) extractLinks(htmlContent string) (map[string]string, error) {
 				defer func() { inTargetElement = false }() // reset to false after leaving the element
 			}
 			if inTargetElement && n.Data == "a" {
-				var linkText string
+				a := anchor{}
+				hasHref := false
 				for _, attr := range n.Attr {
-					if attr.Key == "href" {
-						linkURL := attr.Val
-						for c := n.FirstChild; c != nil; c = c.NextSibling {
-							if c.Type == html.TextNode {
-								linkText += c.Data
-							}
+					switch attr.Key {
+					case "href":
+						a.href = attr.Val
+						hasHref = true
+					case "rel":
+						a.rel = attr.Val
+					}
+				}
+				if hasHref {
+					for c := n.FirstChild; c != nil; c = c.NextSibling {
+						if c.Type == html.TextNode {
+							a.text += c.Data
 						}
-						links[linkURL] = strings.TrimSpace(linkText)
 					}
+					a.text = strings.TrimSpace(a.text)
+					anchors = append(anchors, a)
 				}
+			} else if a, ok := c.sourceAnchor(n, inTargetElement); ok {
+				anchors = append(anchors, a)
 			}
 		}
 		for c := n.FirstChild; c != nil; c = c.NextSibling {
@@ -138,7 +325,52 @@ func (c *Crawler) extractLinks(htmlContent string) (map[string]string, error) {
 	}
 
 	f(doc)
-	return links, nil
+	return anchors, nil
+}
+
+// linkMap maps each link to its anchor text, the last anchor wins for repeated links.
+// Canonical urls are not links to follow.
+func linkMap(anchors []anchor) map[string]string {
+	links := make(map[string]string, len(anchors))
+	for _, a := range anchors {
+		if a.source != "canonical" {
+			links[a.href] = a.text
+		}
+	}
+	return links
+}
+
+// pageLinks extracts the links of a page to follow, leaving out the nofollow ones when
+// RobotsDirectives is set.
+func (c *Crawler) pageLinks(pageURL, htmlContent string) (map[string]string, error) {
+	anchors, ok, err := c.pageAnchors(pageURL, htmlContent)
+	if !ok || err != nil {
+		return nil, err
+	}
+	return linkMap(c.followed(pageURL, anchors)), nil
+}
+
+// pageAnchors extracts every link of a page, recording them in the link graph when RecordGraph is set.
+// Links of a redirected page are made absolute against the url it was fetched from, and
+// duplicates of a canonical page that was crawled already have none, which is reported with
+// false. The feeds seed pages advertise are read here too when Feeds is set.
+func (c *Crawler) pageAnchors(pageURL, htmlContent string) ([]anchor, bool, error) {
+	anchors, err := c.extractAnchors(htmlContent)
+	if err != nil {
+		return nil, false, err
+	}
+	c.seedFeeds(pageURL, htmlContent)
+	if base := c.finalURL(pageURL); base != pageURL {
+		for i := range anchors {
+			anchors[i].href = toAbsoluteURL(base, anchors[i].href)
+		}
+	}
+	if !c.canonicalize(pageURL, anchors) {
+		return nil, false, nil
+	}
+	c.recordEdges(pageURL, anchors)
+	c.metaDirectives(pageURL, htmlContent)
+	return anchors, true, nil
 }
 
 // extractLinks extracts links within the specified element by id or class from the HTML content.
@@ -0,0 +1,262 @@
+package crawler
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"maps"
+	"net/http"
+	"os"
+	"path/filepath"
+)
+
+// errNotModified is returned by requestPage when a conditional request is answered with 304.
+var errNotModified = errors.New("not modified")
+
+// validator is what is remembered about a page between incremental crawls.
+type validator struct {
+	ETag         string            `json:"etag,omitempty"`
+	LastModified string            `json:"last_modified,omitempty"`
+	Hash         string            `json:"hash"`
+	Links        map[string]string `json:"links,omitempty"` // followed again when the page is unchanged
+	Edges        []Edge            `json:"edges,omitempty"` // kept in the link graph when the page is unchanged
+}
+
+// validators holds the validators of the previous crawl and the ones seen in this crawl.
+type validators struct {
+	previous map[string]validator
+	current  map[string]validator
+	gone     map[string]bool
+}
+
+// loadValidators reads a validators file, a missing file means this is the first crawl.
+func loadValidators(path string) (map[string]validator, error) {
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return map[string]validator{}, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read validators: %w", err)
+	}
+	loaded := map[string]validator{}
+	if err := json.Unmarshal(data, &loaded); err != nil {
+		return nil, fmt.Errorf("failed to parse validators %s: %w", path, err)
+	}
+	return loaded, nil
+}
+
+// openValidators loads the validators of the previous crawl when ValidatorsFile is set.
+func (c *Crawler) openValidators() error {
+	if c.ValidatorsFile == "" {
+		c.validators = nil
+		return nil
+	}
+	previous, err := loadValidators(c.ValidatorsFile)
+	if err != nil {
+		return err
+	}
+	c.validators = &validators{
+		previous: previous,
+		current:  make(map[string]validator),
+		gone:     make(map[string]bool),
+	}
+	return nil
+}
+
+// saveValidators writes the validators for the next crawl. Pages that were not
+// visited this time keep their old validators, pages that are gone are dropped.
+func (c *Crawler) saveValidators() error {
+	if c.validators == nil {
+		return nil
+	}
+	c.mutex.Lock()
+	merged := maps.Clone(c.validators.previous)
+	for url := range c.validators.gone {
+		delete(merged, url)
+	}
+	maps.Copy(merged, c.validators.current)
+	c.mutex.Unlock()
+	data, err := json.MarshalIndent(merged, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to save validators: %w", err)
+	}
+	// write to a temporary file first so a crash never leaves a truncated file
+	tmp, err := os.CreateTemp(filepath.Dir(c.ValidatorsFile), ".validators-*")
+	if err != nil {
+		return fmt.Errorf("failed to save validators: %w", err)
+	}
+	_, err = tmp.Write(data)
+	if closeErr := tmp.Close(); err == nil {
+		err = closeErr
+	}
+	if err == nil {
+		err = os.Rename(tmp.Name(), c.ValidatorsFile)
+	}
+	if err != nil {
+		_ = os.Remove(tmp.Name())
+		return fmt.Errorf("failed to save validators: %w", err)
+	}
+	return nil
+}
+
+// conditional adds If-None-Match and If-Modified-Since from the previous crawl to a request.
+// A page answered with 304 has no content, so this is only done when OnlyChanged leaves
+// unchanged pages out anyway.
+func (c *Crawler) conditional(req *http.Request, pageURL string) {
+	if c.validators == nil || !c.OnlyChanged {
+		return
+	}
+	c.mutex.Lock()
+	prev, ok := c.validators.previous[pageURL]
+	c.mutex.Unlock()
+	if !ok {
+		return
+	}
+	if prev.ETag != "" {
+		req.Header.Set("If-None-Match", prev.ETag)
+	}
+	if prev.LastModified != "" {
+		req.Header.Set("If-Modified-Since", prev.LastModified)
+	}
+}
+
+// validate records the validators of a fetched page and whether it is new, changed or unchanged.
+// header is nil for pages rendered with the browser, only the content hash is compared for those.
+func (c *Crawler) validate(pageURL string, header http.Header, body string) {
+	if c.validators == nil {
+		return
+	}
+	sum := sha256.Sum256([]byte(body))
+	v := validator{Hash: hex.EncodeToString(sum[:])}
+	if header != nil {
+		v.ETag = header.Get("ETag")
+		v.LastModified = header.Get("Last-Modified")
+	}
+	c.mutex.Lock()
+	prev, ok := c.validators.previous[pageURL]
+	c.validators.current[pageURL] = v
+	c.mutex.Unlock()
+	status := "new"
+	if ok && prev.Hash == v.Hash {
+		status = "unchanged"
+	} else if ok {
+		status = "changed"
+	}
+	c.recordPage(pageURL, func(r *PageResult) {
+		r.Status = status
+	})
+}
+
+// notModified records a page the server reported as unchanged and returns its links from the previous crawl.
+func (c *Crawler) notModified(pageURL string) map[string]string {
+	c.mutex.Lock()
+	prev := c.validators.previous[pageURL]
+	c.validators.current[pageURL] = prev
+	c.mutex.Unlock()
+	c.recordPage(pageURL, func(r *PageResult) {
+		r.Status = "unchanged"
+		r.LastModified = prev.LastModified
+	})
+	if c.RecordGraph {
+		c.setEdges(pageURL, prev.Edges)
+	}
+	return prev.Links
+}
+
+// markGone records a page from the previous crawl that now answers 404 or 410. Pages no
+// longer linked from anywhere are found by markUnreached.
+func (c *Crawler) markGone(pageURL string) {
+	if c.validators == nil {
+		return
+	}
+	c.mutex.Lock()
+	_, known := c.validators.previous[pageURL]
+	if known {
+		c.validators.gone[pageURL] = true
+	}
+	c.mutex.Unlock()
+	if known {
+		c.recordPage(pageURL, func(r *PageResult) {
+			r.Status = "gone"
+		})
+	}
+}
+
+// markUnreached records the pages of the previous crawl that a crawl run to the end never
+// reached, as no page links to them any more. Crawls stopped or cut short by MaxLinks may
+// not have got to them yet, so their pages are left alone.
+func (c *Crawler) markUnreached(q *queue) {
+	if c.validators == nil || c.stopped.Load() {
+		return
+	}
+	q.mutex.Lock()
+	complete := q.held == nil && q.frontier.Len() == 0
+	seen := maps.Clone(q.seen)
+	q.mutex.Unlock()
+	if !complete {
+		return
+	}
+	c.mutex.Lock()
+	unreached := []string{}
+	for pageURL := range c.validators.previous {
+		if !seen[pageURL] {
+			c.validators.gone[pageURL] = true
+			unreached = append(unreached, pageURL)
+		}
+	}
+	c.mutex.Unlock()
+	for _, pageURL := range unreached {
+		c.recordPage(pageURL, func(r *PageResult) {
+			r.Status = "gone"
+		})
+	}
+}
+
+// rememberLinks stores the links of a page so they can be followed, and kept in the link
+// graph, when it is unchanged next time.
+func (c *Crawler) rememberLinks(pageURL string, links map[string]string) {
+	if c.validators == nil {
+		return
+	}
+	edges := c.pageEdges(pageURL)
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	if v, ok := c.validators.current[pageURL]; ok {
+		v.Links = links
+		v.Edges = edges
+		c.validators.current[pageURL] = v
+	}
+}
+
+// unchanged reports whether a page was found unchanged since the previous crawl.
+func (c *Crawler) unchanged(pageURL string) bool {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	r, ok := c.pageResults[pageURL]
+	return ok && r.Status == "unchanged"
+}
+
+// emitted returns the crawled pages, leaving out unchanged and gone pages when OnlyChanged
+// is set and noindex pages when RobotsDirectives is set.
+func (c *Crawler) emitted() map[string]string {
+	onlyChanged := c.OnlyChanged && c.validators != nil
+	if !onlyChanged && !c.RobotsDirectives {
+		return c.pagesContent
+	}
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	pages := make(map[string]string)
+	for url, content := range c.pagesContent {
+		r, ok := c.pageResults[url]
+		if ok && r.Noindex {
+			continue
+		}
+		if onlyChanged && !(ok && (r.Status == "new" || r.Status == "changed")) {
+			continue
+		}
+		pages[url] = content
+	}
+	return pages
+}
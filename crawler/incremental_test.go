@@ -0,0 +1,125 @@
+package crawler
+
+import (
+	"maps"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"slices"
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestIncrementalCrawl(t *testing.T) {
+	var mutex sync.Mutex
+	pages := map[string]string{
+		"/":        `<a href="/same">same</a><a href="/edited">edited</a><a href="/removed">removed</a><a href="/nocache">nocache</a>`,
+		"/same":    `<a href="/deep">deep</a>`,
+		"/edited":  `version 1 <a href="/old">old</a>`,
+		"/removed": `soon gone`,
+		"/nocache": `no validators`,
+		"/deep":    `deep page`,
+		"/old":     `only linked from version 1`,
+	}
+	conditional := 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mutex.Lock()
+		defer mutex.Unlock()
+		body, ok := pages[r.URL.Path]
+		if !ok {
+			http.NotFound(w, r)
+			return
+		}
+		if r.URL.Path != "/nocache" {
+			etag := `"` + body + `"`
+			w.Header().Set("ETag", etag)
+			if r.Header.Get("If-None-Match") == etag {
+				conditional++
+				w.WriteHeader(http.StatusNotModified)
+				return
+			}
+		}
+		w.Header().Set("Content-Type", "text/html")
+		_, _ = w.Write([]byte(body))
+	}))
+	defer server.Close()
+	file := filepath.Join(t.TempDir(), "validators.json")
+
+	crawl := func(onlyChanged bool) (*Crawler, map[string]string) {
+		c := NewCrawler()
+		c.Silent = true
+		c.MaxDepth = 3
+		c.ValidatorsFile = file
+		c.OnlyChanged = onlyChanged
+		results, err := c.Crawl(server.URL)
+		assert.NoError(t, err)
+		return c, results
+	}
+	statuses := func(c *Crawler) map[string]string {
+		got := map[string]string{}
+		for _, r := range c.Results() {
+			got[r.URL[len(server.URL):]] = r.Status
+		}
+		return got
+	}
+
+	first, results := crawl(false)
+	assert.Len(t, results, 7)
+	assert.Equal(t, map[string]string{
+		"/": "new", "/same": "new", "/edited": "new", "/removed": "new", "/nocache": "new", "/deep": "new", "/old": "new",
+	}, statuses(first))
+
+	mutex.Lock()
+	pages["/edited"] = "version 2"
+	delete(pages, "/removed")
+	mutex.Unlock()
+
+	// the page no longer linked to is gone without being requested
+	second, results := crawl(true)
+	assert.Equal(t, map[string]string{
+		"/": "unchanged", "/same": "unchanged", "/edited": "changed", "/removed": "gone", "/nocache": "unchanged", "/deep": "unchanged", "/old": "gone",
+	}, statuses(second))
+	assert.Equal(t, map[string]string{server.URL + "/edited": "version 2"}, results)
+	assert.Equal(t, 3, conditional, "unchanged pages with an ETag are answered with 304")
+
+	// without OnlyChanged unchanged pages are downloaded again, so they have their content
+	// and items
+	third, results := crawl(false)
+	assert.Equal(t, map[string]string{
+		"/": "unchanged", "/same": "unchanged", "/edited": "unchanged", "/removed": "", "/nocache": "unchanged", "/deep": "unchanged",
+	}, statuses(third))
+	assert.Equal(t, `<a href="/deep">deep</a>`, results[server.URL+"/same"])
+	assert.Equal(t, "version 2", results[server.URL+"/edited"])
+	assert.Equal(t, 3, conditional, "no conditional requests are sent without OnlyChanged")
+
+	c := NewCrawler()
+	c.Silent = true
+	c.MaxDepth = 3
+	c.ValidatorsFile = file
+	items, err := c.Collect(server.URL)
+	assert.NoError(t, err)
+	assert.Contains(t, items, server.URL+"/same")
+	assert.Contains(t, items, server.URL+"/deep")
+	assert.Equal(t, 3, conditional)
+
+	validators, err := loadValidators(file)
+	assert.NoError(t, err)
+	assert.NotContains(t, validators, server.URL+"/removed")
+	assert.NotContains(t, validators, server.URL+"/old")
+	assert.Contains(t, validators, server.URL+"/deep", "links of unchanged pages are still followed")
+
+	// a crawl cut short by MaxLinks may not have got to the other pages yet
+	c = NewCrawler()
+	c.Silent = true
+	c.MaxDepth = 3
+	c.MaxLinks = 2
+	c.ValidatorsFile = file
+	_, err = c.Crawl(server.URL)
+	assert.NoError(t, err)
+	assert.NotContains(t, slices.Collect(maps.Values(statuses(c))), "gone")
+	validators, err = loadValidators(file)
+	assert.NoError(t, err)
+	assert.Contains(t, validators, server.URL+"/deep")
+}
@@ -0,0 +1,186 @@
+package crawler
+
+import (
+	"net/url"
+	"slices"
+	"strings"
+)
+
+// DefaultTrackingParams are query parameters that only track visitors and never change page content.
+// Entries ending in "*" match any parameter with that prefix.
+var DefaultTrackingParams = []string{
+	"utm_*", "gclid", "dclid", "fbclid", "msclkid", "yclid", "mc_cid", "mc_eid", "igshid", "_ga", "_hsenc", "_hsmi",
+}
+
+// Normalizer canonicalizes URLs so equivalent addresses are only crawled once.
+type Normalizer struct {
+	// TrackingParams are query parameters removed from URLs, "utm_*" style prefixes are supported.
+	TrackingParams []string
+	// TrailingSlash is the policy for paths other than "/": "keep", "add" or "remove".
+	TrailingSlash string
+}
+
+// NewNormalizer returns a normalizer that strips DefaultTrackingParams and trailing slashes.
+func NewNormalizer() Normalizer {
+	return Normalizer{
+		TrackingParams: slices.Clone(DefaultTrackingParams),
+		TrailingSlash:  "remove",
+	}
+}
+
+// NormalizeURL canonicalizes a URL with the default normalizer.
+// URLs that can't be parsed, or are not http(s), are returned unchanged.
+func NormalizeURL(rawURL string) string {
+	return NewNormalizer().Normalize(rawURL)
+}
+
+// Normalize lowercases the scheme and host, drops default ports and fragments,
+// normalizes percent-encoding and dot segments, sorts query parameters,
+// removes tracking parameters and applies the trailing slash policy.
+// URLs that can't be parsed, or are not http(s), are returned unchanged.
+func (n Normalizer) Normalize(rawURL string) string {
+	u, err := url.Parse(strings.TrimSpace(rawURL))
+	if err != nil {
+		return rawURL
+	}
+	scheme := strings.ToLower(u.Scheme)
+	if scheme != "http" && scheme != "https" || u.Host == "" {
+		return rawURL
+	}
+	host := strings.ToLower(u.Hostname())
+	if strings.Contains(host, ":") {
+		// IPv6 literals keep their brackets
+		host = "[" + host + "]"
+	}
+	if port := u.Port(); port != "" && !(scheme == "http" && port == "80") && !(scheme == "https" && port == "443") {
+		host += ":" + port
+	}
+
+	// resolving against an empty reference removes "." and ".." segments
+	resolved := u.ResolveReference(&url.URL{})
+	path := normalizeEscapes(resolved.EscapedPath())
+	if path == "" {
+		path = "/"
+	}
+	if path != "/" {
+		switch n.TrailingSlash {
+		case "remove":
+			path = strings.TrimRight(path, "/")
+			if path == "" {
+				path = "/"
+			}
+		case "add":
+			if !strings.HasSuffix(path, "/") {
+				path += "/"
+			}
+		}
+	}
+
+	var sb strings.Builder
+	sb.WriteString(scheme)
+	sb.WriteString("://")
+	if u.User != nil {
+		sb.WriteString(u.User.String())
+		sb.WriteString("@")
+	}
+	sb.WriteString(host)
+	sb.WriteString(path)
+	if query := n.normalizeQuery(u.RawQuery); query != "" {
+		sb.WriteString("?")
+		sb.WriteString(query)
+	}
+	return sb.String()
+}
+
+// normalizeQuery sorts query parameters by key and removes tracking parameters.
+func (n Normalizer) normalizeQuery(rawQuery string) string {
+	type param struct {
+		key  string
+		pair string
+	}
+	params := []param{}
+	for _, pair := range strings.Split(rawQuery, "&") {
+		if pair == "" {
+			continue
+		}
+		pair = normalizeEscapes(pair)
+		key, _, _ := strings.Cut(pair, "=")
+		if decoded, err := url.QueryUnescape(key); err == nil {
+			key = decoded
+		}
+		if n.isTrackingParam(key) {
+			continue
+		}
+		params = append(params, param{key: key, pair: pair})
+	}
+	slices.SortStableFunc(params, func(a, b param) int {
+		return strings.Compare(a.key, b.key)
+	})
+	pairs := make([]string, len(params))
+	for i, p := range params {
+		pairs[i] = p.pair
+	}
+	return strings.Join(pairs, "&")
+}
+
+func (n Normalizer) isTrackingParam(key string) bool {
+	key = strings.ToLower(key)
+	for _, param := range n.TrackingParams {
+		param = strings.ToLower(param)
+		if prefix, ok := strings.CutSuffix(param, "*"); ok {
+			if strings.HasPrefix(key, prefix) {
+				return true
+			}
+		} else if key == param {
+			return true
+		}
+	}
+	return false
+}
+
+// normalizeEscapes decodes percent-encoded unreserved characters and uppercases the remaining escapes.
+func normalizeEscapes(s string) string {
+	if !strings.Contains(s, "%") {
+		return s
+	}
+	var sb strings.Builder
+	for i := 0; i < len(s); i++ {
+		if s[i] != '%' || i+2 >= len(s) || !isHex(s[i+1]) || !isHex(s[i+2]) {
+			sb.WriteByte(s[i])
+			continue
+		}
+		b := unhex(s[i+1])<<4 | unhex(s[i+2])
+		if isUnreserved(b) {
+			sb.WriteByte(b)
+		} else {
+			sb.WriteString(strings.ToUpper(s[i : i+3]))
+		}
+		i += 2
+	}
+	return sb.String()
+}
+
+func isUnreserved(b byte) bool {
+	return 'a' <= b && b <= 'z' || 'A' <= b && b <= 'Z' || '0' <= b && b <= '9' ||
+		b == '-' || b == '.' || b == '_' || b == '~'
+}
+
+func isHex(b byte) bool {
+	return '0' <= b && b <= '9' || 'a' <= b && b <= 'f' || 'A' <= b && b <= 'F'
+}
+
+func unhex(b byte) byte {
+	switch {
+	case '0' <= b && b <= '9':
+		return b - '0'
+	case 'a' <= b && b <= 'f':
+		return b - 'a' + 10
+	default:
+		return b - 'A' + 10
+	}
+}
+
+// normalize canonicalizes a URL with the crawler's normalizer.
+func (c *Crawler) normalize(rawURL string) string {
+	return c.Normalizer.Normalize(rawURL)
+}
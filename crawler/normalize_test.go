@@ -0,0 +1,45 @@
+package crawler
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestNormalizeURL(t *testing.T) {
+	tests := map[string]string{
+		"https://a.com/x":                          "https://a.com/x",
+		"https://A.com/x/":                         "https://a.com/x",
+		"https://a.com/x#top":                      "https://a.com/x",
+		"https://a.com/x?utm_source=y":             "https://a.com/x",
+		"HTTPS://a.com:443/x":                      "https://a.com/x",
+		"http://a.com:80":                          "http://a.com/",
+		"http://a.com:8080/":                       "http://a.com:8080/",
+		"https://a.com/%7euser/%2f%41":             "https://a.com/~user/%2FA",
+		"https://a.com/a/./b/../c":                 "https://a.com/a/c",
+		"https://a.com/?b=2&a=1&fbclid=abc&a=0":    "https://a.com/?a=1&a=0&b=2",
+		"https://a.com/search?q=go%20lang&utm_x=1": "https://a.com/search?q=go%20lang",
+		"http://[::1]:8080/x":                      "http://[::1]:8080/x",
+		"mailto:someone@a.com":                     "mailto:someone@a.com",
+		"/relative/path":                           "/relative/path",
+	}
+	for input, want := range tests {
+		assert.Equal(t, want, NormalizeURL(input), input)
+	}
+}
+
+func TestNormalizerTrailingSlash(t *testing.T) {
+	n := NewNormalizer()
+	n.TrailingSlash = "add"
+	assert.Equal(t, "https://a.com/x/", n.Normalize("https://a.com/x"))
+	assert.Equal(t, "https://a.com/", n.Normalize("https://a.com"))
+	n.TrailingSlash = "keep"
+	assert.Equal(t, "https://a.com/x", n.Normalize("https://a.com/x"))
+	assert.Equal(t, "https://a.com/x/", n.Normalize("https://a.com/x/"))
+}
+
+func TestNormalizerTrackingParams(t *testing.T) {
+	n := NewNormalizer()
+	n.TrackingParams = []string{"ref"}
+	assert.Equal(t, "https://a.com/x?utm_source=y", n.Normalize("https://a.com/x?ref=home&utm_source=y"))
+}
@@ -0,0 +1,261 @@
+package crawler
+
+import (
+	"errors"
+	"fmt"
+	"maps"
+	"slices"
+	"strings"
+	"sync"
+)
+
+// visitFunc fetches and processes a single page and returns the links found on it.
+type visitFunc func(entry Entry) (map[string]string, error)
+
+// queue is the frontier shared by the worker pool along with the bookkeeping
+// needed to know when the crawl is finished.
+type queue struct {
+	mutex      sync.Mutex
+	cond       *sync.Cond
+	frontier   Frontier
+	seen       map[string]bool // urls that have been queued or crawled
+	active     map[int]int     // pages being visited, by depth
+	held       *Entry          // popped entry waiting for shallower pages to finish
+	claimed    int             // pages handed to workers
+	levelOrder bool            // only start a page once every shallower page has been expanded
+}
+
+// run crawls the seeds with a fixed pool of workers pulling from the frontier.
+// verb is used when reporting errors, such as "crawling" or "collecting".
+func (c *Crawler) run(seeds []string, verb string, visit visitFunc) error {
+	frontier := c.Frontier
+	if frontier == nil {
+		var err error
+		frontier, err = NewFrontier(c.Order)
+		if err != nil {
+			return err
+		}
+	}
+	q := &queue{
+		frontier: frontier,
+		seen:     make(map[string]bool),
+		active:   make(map[int]int),
+		// with breadth first order and a page limit, wait for each level to be expanded
+		// so the limit always keeps the shallowest pages
+		levelOrder: c.Frontier == nil && (c.Order == "" || c.Order == "bfs") && c.MaxLinks > 0,
+	}
+	q.cond = sync.NewCond(&q.mutex)
+	c.mutex.Lock()
+	c.queue = q
+	c.mutex.Unlock()
+	defer func() {
+		c.mutex.Lock()
+		c.queue = nil
+		c.mutex.Unlock()
+	}()
+
+	c.stopped.Store(false)
+	if c.Resume && c.StateDir == "" {
+		return errors.New("resume needs a state directory")
+	}
+	if c.StateDir != "" {
+		st, err := openState(c.StateDir, c.Resume)
+		if err != nil {
+			return err
+		}
+		c.state = st
+		if c.Resume {
+			if err := c.restore(q); err != nil {
+				return errors.Join(err, c.closeState())
+			}
+		}
+	}
+	if err := c.openValidators(); err != nil {
+		return errors.Join(err, c.closeState())
+	}
+	if err := c.loadCookies(); err != nil {
+		return errors.Join(err, c.closeState())
+	}
+	if err := c.openTape(); err != nil {
+		return errors.Join(err, c.closeState())
+	}
+	if err := c.openProxies(); err != nil {
+		return errors.Join(err, c.closeState())
+	}
+	if err := c.login(); err != nil {
+		return errors.Join(err, c.closeState())
+	}
+	c.mutex.Lock()
+	for url := range c.pagesContent {
+		q.seen[url] = true
+	}
+	c.mutex.Unlock()
+	for _, url := range c.Selectors.ExcludedUrls {
+		q.seen[c.normalize(url)] = true
+	}
+	if c.Feeds {
+		seeds = c.feedSeeds(seeds)
+	}
+	for _, url := range seeds {
+		c.enqueue(q, url, "", 1)
+	}
+	if c.Sitemaps {
+		for _, url := range c.sitemapSeeds(seeds) {
+			c.enqueue(q, url, "", 1)
+		}
+	}
+
+	workers := c.Threads
+	if workers < 1 {
+		workers = 1 // Default to 1 if not set
+	}
+	var wg sync.WaitGroup
+	for range workers {
+		wg.Go(func() {
+			for {
+				entry, ok := c.next(q)
+				if !ok {
+					return
+				}
+				if entry.Link != "" {
+					c.mutex.Lock()
+					c.links[entry.URL] = entry.Link
+					c.mutex.Unlock()
+				}
+				links, err := visit(entry)
+				if err != nil {
+					c.mutex.Lock()
+					c.errors = append(c.errors, err)
+					c.mutex.Unlock()
+					if !c.Silent {
+						fmt.Printf("Error %s %s: %v\n", verb, entry.URL, err)
+					}
+				}
+				c.done(q, entry, links)
+				c.attachFeed(entry.URL)
+				c.checkpoint(entry)
+			}
+		})
+	}
+	wg.Wait() // Wait for all workers to finish
+	c.markUnreached(q)
+	return errors.Join(c.saveValidators(), c.saveCookies(), c.saveTape(), c.closeState())
+}
+
+// Stop ends a running crawl once the pages being fetched have finished.
+// With StateDir set the crawl can be picked up again by setting Resume.
+func (c *Crawler) Stop() {
+	c.stopped.Store(true)
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	if c.queue != nil {
+		c.queue.mutex.Lock()
+		c.queue.cond.Broadcast()
+		c.queue.mutex.Unlock()
+	}
+}
+
+// closeState flushes and closes the crawl state, if any.
+func (c *Crawler) closeState() error {
+	if c.state == nil {
+		return nil
+	}
+	err := c.state.close()
+	c.state = nil
+	return err
+}
+
+// next blocks until a page can be crawled, returning false once the crawl is finished.
+func (c *Crawler) next(q *queue) (Entry, bool) {
+	q.mutex.Lock()
+	defer q.mutex.Unlock()
+	for {
+		if c.stopped.Load() || c.MaxLinks > 0 && q.claimed >= c.MaxLinks {
+			return Entry{}, false
+		}
+		if q.held == nil {
+			if entry, ok := q.frontier.Pop(); ok {
+				q.held = &entry
+			}
+		}
+		if q.held != nil && q.ready(*q.held) {
+			entry := *q.held
+			q.held = nil
+			q.claimed++
+			q.active[entry.Depth]++
+			return entry, true
+		}
+		if q.held == nil && len(q.active) == 0 {
+			// nothing queued and nothing running that could queue more
+			q.cond.Broadcast()
+			return Entry{}, false
+		}
+		q.cond.Wait()
+	}
+}
+
+// ready reports whether an entry can start without breaking level order.
+func (q *queue) ready(entry Entry) bool {
+	if !q.levelOrder {
+		return true
+	}
+	for depth := range q.active {
+		if entry.Depth > depth+1 {
+			return false
+		}
+	}
+	return true
+}
+
+// done queues the links found on a visited page and wakes up waiting workers.
+// Links are queued in sorted order so crawls of the same site are repeatable.
+func (c *Crawler) done(q *queue, entry Entry, links map[string]string) {
+	for _, link := range slices.Sorted(maps.Keys(links)) {
+		linkText := links[link]
+		if !c.linkTextCheck(link, linkText) {
+			continue
+		}
+		fullURL := toAbsoluteURL(entry.URL, link)
+		if !c.validDomainCheck(c.normalize(fullURL)) {
+			continue
+		}
+		c.enqueue(q, fullURL, linkText, entry.Depth+1)
+	}
+	q.mutex.Lock()
+	q.active[entry.Depth]--
+	if q.active[entry.Depth] == 0 {
+		delete(q.active, entry.Depth)
+	}
+	q.cond.Broadcast()
+	q.mutex.Unlock()
+}
+
+// enqueue adds a url to the frontier unless it is too deep or its normalized form was already seen.
+func (c *Crawler) enqueue(q *queue, link, linkText string, depth int) {
+	if depth > c.MaxDepth {
+		return
+	}
+	pageURL := c.normalize(link)
+	entry := Entry{URL: pageURL, Depth: depth}
+	if link, _, _ = strings.Cut(link, "#"); link != pageURL {
+		entry.Link = link
+	}
+	if c.Order == "priority" || c.Score != nil {
+		score := c.Score
+		if score == nil {
+			score = c.defaultScore
+		}
+		entry.Priority = score(pageURL, linkText, depth)
+	}
+	q.mutex.Lock()
+	defer q.mutex.Unlock()
+	if q.seen[pageURL] {
+		return
+	}
+	q.seen[pageURL] = true
+	q.frontier.Push(entry)
+	if c.state != nil {
+		c.state.queued(entry)
+	}
+	q.cond.Broadcast()
+}
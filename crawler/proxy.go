@@ -0,0 +1,309 @@
+package crawler
+
+import (
+	"errors"
+	"fmt"
+	"maps"
+	"net"
+	"net/http"
+	"net/url"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	// proxyRetryAfter is how long a failing proxy is left out of the rotation.
+	proxyRetryAfter = 30 * time.Second
+	// proxyCheckTimeout bounds the health check of each proxy.
+	proxyCheckTimeout = 5 * time.Second
+)
+
+// proxy is a proxy of the rotating pool.
+type proxy struct {
+	url      *url.URL
+	failedAt time.Time // zero while the proxy works
+}
+
+// proxyPool routes requests through the configured proxies.
+type proxyPool struct {
+	mutex      sync.Mutex
+	proxies    []*proxy
+	rules      map[string]*url.URL // by domain, nil to connect directly
+	next       int
+	transports map[string]*http.Transport // by proxy url, "" for direct connections
+}
+
+// parseProxy parses a proxy url, "host:port" means an HTTP proxy.
+func parseProxy(value string) (*url.URL, error) {
+	if !strings.Contains(value, "://") {
+		value = "http://" + value
+	}
+	u, err := url.Parse(value)
+	if err != nil {
+		return nil, fmt.Errorf("invalid proxy %s: %w", value, err)
+	}
+	if !slices.Contains([]string{"http", "https", "socks5", "socks5h"}, u.Scheme) || u.Host == "" {
+		return nil, fmt.Errorf("invalid proxy %s: expected an http, https or socks5 url", value)
+	}
+	return u, nil
+}
+
+// openProxies prepares the proxy pool and rules, leaving out pool proxies that can't be reached.
+func (c *Crawler) openProxies() error {
+	c.mutex.Lock()
+	c.proxies = nil
+	c.mutex.Unlock()
+	pool, err := c.proxyPool()
+	if pool == nil || err != nil {
+		return err
+	}
+	working := 0
+	for _, p := range pool.proxies {
+		conn, err := net.DialTimeout("tcp", p.url.Host, min(time.Duration(c.Timeout)*time.Second, proxyCheckTimeout))
+		if err != nil {
+			pool.report(p.url, false)
+			if !c.Silent {
+				fmt.Printf("Warning: proxy %s is not reachable: %v\n", p.url.Redacted(), err)
+			}
+			continue
+		}
+		_ = conn.Close()
+		working++
+	}
+	if len(pool.proxies) > 0 && working == 0 {
+		return errors.New("none of the proxies can be reached")
+	}
+	return nil
+}
+
+// proxyPool returns the proxies of the crawler, nil when none are configured.
+func (c *Crawler) proxyPool() (*proxyPool, error) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	if c.proxies != nil || len(c.Proxies) == 0 && len(c.ProxyRules) == 0 {
+		return c.proxies, nil
+	}
+	pool := &proxyPool{rules: make(map[string]*url.URL), transports: make(map[string]*http.Transport)}
+	for _, value := range c.Proxies {
+		u, err := parseProxy(value)
+		if err != nil {
+			return nil, err
+		}
+		pool.proxies = append(pool.proxies, &proxy{url: u})
+	}
+	for domain, value := range c.ProxyRules {
+		domain = strings.ToLower(domain)
+		if strings.EqualFold(value, "direct") {
+			pool.rules[domain] = nil
+			continue
+		}
+		u, err := parseProxy(value)
+		if err != nil {
+			return nil, err
+		}
+		pool.rules[domain] = u
+	}
+	if err := c.checkBrowserProxies(pool); err != nil {
+		return nil, err
+	}
+	c.proxies = pool
+	return pool, nil
+}
+
+// checkBrowserProxies rejects proxies with credentials when pages are rendered by a launched
+// chrome. The PAC script routing it can only name proxies, so their requests would fail
+// with 407 Proxy Authentication Required.
+func (c *Crawler) checkBrowserProxies(pool *proxyPool) error {
+	if c.JsDepth <= 0 && !c.JsAuto || c.BrowserWSURL != "" {
+		return nil
+	}
+	proxies := slices.Collect(maps.Values(pool.rules))
+	for _, p := range pool.proxies {
+		proxies = append(proxies, p.url)
+	}
+	for _, proxyURL := range proxies {
+		if proxyURL != nil && proxyURL.User != nil {
+			return fmt.Errorf("proxy %s needs credentials, which can't be passed to chrome for javascript rendering: "+
+				"use a proxy without them or a running chrome set up with the proxy", proxyURL.Redacted())
+		}
+	}
+	return nil
+}
+
+// candidates returns the proxies to try for a request in order, nil meaning a direct
+// connection. Domains with a rule always use it, other requests take turns on the
+// pool, with failing proxies only tried last.
+func (p *proxyPool) candidates(u *url.URL) []*url.URL {
+	host := strings.ToLower(u.Hostname())
+	match, found := "", false
+	var ruled *url.URL
+	for domain, proxyURL := range p.rules {
+		if domainMatch(host, domain) && len(domain) >= len(match) {
+			match, ruled, found = domain, proxyURL, true
+		}
+	}
+	if found {
+		return []*url.URL{ruled}
+	}
+	if len(p.proxies) == 0 {
+		return []*url.URL{nil}
+	}
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+	start := p.next
+	p.next = (p.next + 1) % len(p.proxies)
+	working, failing := []*url.URL{}, []*url.URL{}
+	for i := range p.proxies {
+		pr := p.proxies[(start+i)%len(p.proxies)]
+		if pr.failedAt.IsZero() || time.Since(pr.failedAt) > proxyRetryAfter {
+			working = append(working, pr.url)
+		} else {
+			failing = append(failing, pr.url)
+		}
+	}
+	return append(working, failing...)
+}
+
+// report records whether a request through a pool proxy reached it.
+func (p *proxyPool) report(proxyURL *url.URL, ok bool) {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+	for _, pr := range p.proxies {
+		if pr.url == proxyURL {
+			if ok {
+				pr.failedAt = time.Time{}
+			} else {
+				pr.failedAt = time.Now()
+			}
+		}
+	}
+}
+
+// pooled reports whether a proxy belongs to the rotating pool, so failing over is possible.
+func (p *proxyPool) pooled(proxyURL *url.URL) bool {
+	return proxyURL != nil && slices.ContainsFunc(p.proxies, func(pr *proxy) bool {
+		return pr.url == proxyURL
+	})
+}
+
+// transport returns the shared transport sending requests through a proxy, or directly for nil.
+func (p *proxyPool) transport(proxyURL *url.URL) *http.Transport {
+	key := ""
+	if proxyURL != nil {
+		key = proxyURL.String()
+	}
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+	t, ok := p.transports[key]
+	if !ok {
+		t = http.DefaultTransport.(*http.Transport).Clone()
+		t.Proxy = nil
+		if proxyURL != nil {
+			t.Proxy = http.ProxyURL(proxyURL)
+		}
+		p.transports[key] = t
+	}
+	return t
+}
+
+// isProxyError reports whether a request failed reaching the proxy rather than the site.
+func isProxyError(err error) bool {
+	var opErr *net.OpError
+	return errors.As(err, &opErr) && (opErr.Op == "proxyconnect" || strings.HasPrefix(opErr.Op, "socks"))
+}
+
+// sendProxied sends a request through the proxies for its url, failing over to the
+// next pool proxy when one can't be reached.
+func (c *Crawler) sendProxied(client *http.Client, pool *proxyPool, req *http.Request) (*http.Response, error) {
+	var lastErr error
+	for i, proxyURL := range pool.candidates(req.URL) {
+		attempt := req
+		if i > 0 {
+			attempt = req.Clone(req.Context())
+			if req.GetBody != nil {
+				body, err := req.GetBody()
+				if err != nil {
+					return nil, err
+				}
+				attempt.Body = body
+			}
+		}
+		proxied := *client
+		proxied.Transport = c.transport(pool.transport(proxyURL))
+		resp, err := proxied.Do(attempt)
+		if !pool.pooled(proxyURL) {
+			return resp, err
+		}
+		if err == nil || !isProxyError(err) {
+			pool.report(proxyURL, true)
+			return resp, err
+		}
+		pool.report(proxyURL, false)
+		if !c.Silent {
+			fmt.Printf("Warning: proxy %s failed, trying the next one: %v\n", proxyURL.Redacted(), err)
+		}
+		lastErr = err
+		if req.Body != nil && req.GetBody == nil {
+			break // the body was used up and can't be sent again
+		}
+	}
+	return nil, lastErr
+}
+
+// pacScript returns a proxy auto-config script with the same routing for the browser.
+// Chrome fails over between the pool proxies in order instead of rotating them.
+func (p *proxyPool) pacScript() string {
+	if p == nil {
+		return ""
+	}
+	var sb strings.Builder
+	sb.WriteString("function FindProxyForURL(url, host) {\n")
+	domains := make([]string, 0, len(p.rules))
+	for domain := range p.rules {
+		domains = append(domains, domain)
+	}
+	// most specific domains first
+	slices.SortFunc(domains, func(a, b string) int {
+		if n := len(b) - len(a); n != 0 {
+			return n
+		}
+		return strings.Compare(a, b)
+	})
+	for _, domain := range domains {
+		fmt.Fprintf(&sb, "  if (host == %q || dnsDomainIs(host, %q)) return %q;\n",
+			domain, "."+domain, pacProxy(p.rules[domain]))
+	}
+	route := "DIRECT"
+	if len(p.proxies) > 0 {
+		working, failing := []string{}, []string{}
+		p.mutex.Lock()
+		for _, pr := range p.proxies {
+			if pr.failedAt.IsZero() {
+				working = append(working, pacProxy(pr.url))
+			} else {
+				failing = append(failing, pacProxy(pr.url))
+			}
+		}
+		p.mutex.Unlock()
+		route = strings.Join(append(working, failing...), "; ")
+	}
+	fmt.Fprintf(&sb, "  return %q;\n}\n", route)
+	return sb.String()
+}
+
+// pacProxy is a proxy as written in PAC results.
+func pacProxy(proxyURL *url.URL) string {
+	if proxyURL == nil {
+		return "DIRECT"
+	}
+	switch proxyURL.Scheme {
+	case "https":
+		return "HTTPS " + proxyURL.Host
+	case "socks5", "socks5h":
+		return "SOCKS5 " + proxyURL.Host
+	default:
+		return "PROXY " + proxyURL.Host
+	}
+}
@@ -0,0 +1,112 @@
+package crawler
+
+import (
+	"net/http"
+	"slices"
+)
+
+// Redirect is a redirect followed while fetching a page.
+type Redirect struct {
+	URL        string `json:"url"` // the url that answered with the redirect
+	StatusCode int    `json:"status_code"`
+}
+
+// redirectChain returns the redirects that led to a response, in the order they were
+// followed, and the url they ended at. The response is itself a redirect when following
+// it was refused.
+func redirectChain(resp *http.Response) ([]Redirect, string) {
+	chain := []Redirect{}
+	finalURL := resp.Request.URL.String()
+	if location, err := resp.Location(); err == nil && resp.StatusCode/100 == 3 {
+		chain = append(chain, Redirect{URL: finalURL, StatusCode: resp.StatusCode})
+		finalURL = location.String()
+	}
+	for r := resp.Request; r.Response != nil; r = r.Response.Request {
+		chain = append(chain, Redirect{URL: r.Response.Request.URL.String(), StatusCode: r.Response.StatusCode})
+	}
+	slices.Reverse(chain)
+	return chain, finalURL
+}
+
+// followRedirect checks each redirect of a page against the crawl rules before it is
+// followed, claiming the target so the crawl doesn't fetch it a second time.
+func (c *Crawler) followRedirect(pageURL string) func(*http.Request) error {
+	return func(next *http.Request) error {
+		return c.redirected(pageURL, next.URL.String())
+	}
+}
+
+// redirected checks where a page was redirected to. Targets outside the crawled domains,
+// excluded urls and pages that were already crawled or queued are skipped.
+func (c *Crawler) redirected(pageURL, target string) error {
+	target = c.normalize(target)
+	if target == pageURL {
+		return nil
+	}
+	if !c.validDomainCheck(target) {
+		return &skippedError{reason: "redirects outside the crawled domains to " + target}
+	}
+	for _, excluded := range c.Selectors.ExcludedUrls {
+		if c.normalize(excluded) == target {
+			return &skippedError{reason: "redirects to excluded " + target}
+		}
+	}
+	if !c.claim(target) {
+		return &skippedError{reason: "redirects to " + target + ", which is crawled already"}
+	}
+	return nil
+}
+
+// claim marks a url as visited in the running crawl, returning false when it already was.
+func (c *Crawler) claim(pageURL string) bool {
+	c.mutex.Lock()
+	q := c.queue
+	c.mutex.Unlock()
+	if q == nil {
+		return true
+	}
+	q.mutex.Lock()
+	defer q.mutex.Unlock()
+	if q.seen[pageURL] {
+		return false
+	}
+	q.seen[pageURL] = true
+	return true
+}
+
+// recordRedirects stores the redirects followed for a page and where it ended up, which
+// without redirects is still the url as linked when normalizing changed it.
+func (c *Crawler) recordRedirects(pageURL, finalURL string, chain []Redirect) {
+	if len(chain) == 0 && (finalURL == "" || finalURL == pageURL) {
+		return
+	}
+	c.recordPage(pageURL, func(r *PageResult) {
+		r.Redirects = chain
+		r.FinalURL = finalURL
+	})
+}
+
+// finalURL returns the url a page was fetched from after redirects, which its relative links resolve against.
+func (c *Crawler) finalURL(pageURL string) string {
+	c.mutex.Lock()
+	finalURL := ""
+	if r, ok := c.pageResults[pageURL]; ok {
+		finalURL = r.FinalURL
+	}
+	c.mutex.Unlock()
+	if finalURL != "" {
+		return finalURL
+	}
+	return c.fetchURL(pageURL)
+}
+
+// fetchURL returns the url a page is requested from: the url as it was linked, which
+// normalizing may have changed into a different resource, such as by removing a trailing slash.
+func (c *Crawler) fetchURL(pageURL string) string {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	if link, ok := c.links[pageURL]; ok {
+		return link
+	}
+	return pageURL
+}
@@ -0,0 +1,82 @@
+package crawler
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRedirects(t *testing.T) {
+	var mutex sync.Mutex
+	fetches := map[string]int{}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mutex.Lock()
+		fetches[r.URL.Path]++
+		mutex.Unlock()
+		w.Header().Set("Content-Type", "text/html")
+		switch r.URL.Path {
+		case "/":
+			_, _ = w.Write([]byte(`<a href="/old">old</a><a href="/away">away</a>
+				<a href="/home">home</a><a href="/loop">loop</a><a href="/excluded-old">excluded</a>`))
+		case "/old":
+			http.Redirect(w, r, "/moved", http.StatusMovedPermanently)
+		case "/moved":
+			http.Redirect(w, r, "/docs/new", http.StatusFound)
+		case "/docs/new":
+			_, _ = w.Write([]byte(`<a href="next">next</a>`))
+		case "/away":
+			http.Redirect(w, r, "http://example.invalid/", http.StatusFound)
+		case "/home":
+			http.Redirect(w, r, "/", http.StatusFound)
+		case "/loop":
+			http.Redirect(w, r, "/loop?n="+r.URL.Query().Get("n")+"1", http.StatusFound)
+		case "/excluded-old":
+			http.Redirect(w, r, "/private", http.StatusFound)
+		default:
+			_, _ = w.Write([]byte(`page`))
+		}
+	}))
+	defer server.Close()
+
+	c := NewCrawler()
+	c.Silent = true
+	c.MaxDepth = 3
+	c.MaxRedirects = 3
+	c.Selectors.ExcludeDomains = []string{"example.invalid"}
+	c.Selectors.ExcludedUrls = []string{server.URL + "/private"}
+	_, err := c.Crawl(server.URL + "/")
+	assert.NoError(t, err)
+
+	results := map[string]PageResult{}
+	for _, r := range c.Results() {
+		results[strings.TrimPrefix(r.URL, server.URL)] = r
+	}
+	old := results["/old"]
+	assert.Equal(t, []Redirect{
+		{URL: server.URL + "/old", StatusCode: http.StatusMovedPermanently},
+		{URL: server.URL + "/moved", StatusCode: http.StatusFound},
+	}, old.Redirects)
+	assert.Equal(t, server.URL+"/docs/new", old.FinalURL)
+	assert.Empty(t, old.Skipped)
+
+	assert.Equal(t, "redirects outside the crawled domains to http://example.invalid/", results["/away"].Skipped)
+	assert.Equal(t, []Redirect{{URL: server.URL + "/away", StatusCode: http.StatusFound}}, results["/away"].Redirects)
+	assert.Equal(t, "redirects to "+server.URL+"/, which is crawled already", results["/home"].Skipped)
+	assert.Equal(t, "redirects to excluded "+server.URL+"/private", results["/excluded-old"].Skipped)
+	assert.Contains(t, results["/loop"].Error, "stopped after 3 redirects")
+	assert.Len(t, results["/loop"].Redirects, 4) // three followed and the one refused
+
+	// relative links resolve against the url the page was fetched from
+	assert.Contains(t, results, "/docs/next")
+	_, ok := results["/next"]
+	assert.False(t, ok)
+	mutex.Lock()
+	defer mutex.Unlock()
+	assert.Equal(t, 1, fetches["/docs/new"])
+	assert.Equal(t, 1, fetches["/"])
+	assert.Zero(t, fetches["/private"])
+}
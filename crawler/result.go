@@ -0,0 +1,124 @@
+package crawler
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+)
+
+// PageResult is the record kept for each page fetched during a crawl.
+type PageResult struct {
+	URL          string     `json:"url"`
+	Depth        int        `json:"depth"`
+	StatusCode   int        `json:"status_code,omitempty"`   // HTTP status of the response
+	Redirects    []Redirect `json:"redirects,omitempty"`     // redirects answered on the way to FinalURL
+	FinalURL     string     `json:"final_url,omitempty"`     // where the page was fetched from after redirects, when not URL
+	Canonical    string     `json:"canonical,omitempty"`     // the canonical url the page declares, when LinkSources has "canonical"
+	Noindex      bool       `json:"noindex,omitempty"`       // asked not to be indexed, left out of Results when RobotsDirectives is set
+	Nofollow     bool       `json:"nofollow,omitempty"`      // asked for its links not to be followed
+	Status       string     `json:"status,omitempty"`        // "new", "changed", "unchanged" or "gone" when ValidatorsFile is set
+	Javascript   bool       `json:"javascript,omitempty"`    // rendered with the browser
+	LastModified string     `json:"last_modified,omitempty"` // Last-Modified response header
+	ContentType  string     `json:"content_type,omitempty"`  // Content-Type response header, or the sniffed type
+	Charset      string     `json:"charset,omitempty"`       // character set the page was decoded from
+	Screenshot   string     `json:"screenshot,omitempty"`
+	PDF          string     `json:"pdf,omitempty"`
+	CaptureError string     `json:"capture_error,omitempty"` // why a screenshot or PDF could not be saved
+	Blocked      []string   `json:"blocked,omitempty"`       // requests skipped while rendering javascript
+	Items        []string   `json:"items,omitempty"`         // items gathered from the page by Collect
+	Feed         *FeedEntry `json:"feed,omitempty"`          // the feed entry linking to the page
+	Skipped      string     `json:"skipped,omitempty"`       // why the page was not parsed, such as its content type or size
+	Error        string     `json:"error,omitempty"`         // why the page could not be fetched
+}
+
+// Page is a crawled page with its content and result, as saved by WritePages and in StateDir.
+type Page struct {
+	URL     string      `json:"url"`
+	Depth   int         `json:"depth"`
+	Content string      `json:"content,omitempty"`
+	Result  *PageResult `json:"result,omitempty"`
+	Edges   []Edge      `json:"edges,omitempty"` // the links on the page when RecordGraph is set
+}
+
+// Results returns the page records gathered by Crawl or Collect, sorted by URL.
+// Pages marked noindex are left out.
+func (c *Crawler) Results() []PageResult {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	results := make([]PageResult, 0, len(c.pageResults))
+	for _, r := range c.pageResults {
+		if !r.Noindex {
+			results = append(results, *r)
+		}
+	}
+	slices.SortFunc(results, func(a, b PageResult) int {
+		return strings.Compare(a.URL, b.URL)
+	})
+	return results
+}
+
+// recordPage creates or updates the result record for a page.
+func (c *Crawler) recordPage(pageURL string, update func(*PageResult)) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	r, ok := c.pageResults[pageURL]
+	if !ok {
+		r = &PageResult{URL: pageURL}
+		c.pageResults[pageURL] = r
+	}
+	update(r)
+}
+
+// Pages returns every page of the last Crawl or Collect with its content and result, sorted by URL.
+// Pages marked noindex are left out.
+func (c *Crawler) Pages() []Page {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	pages := make([]Page, 0, len(c.pagesContent))
+	for url, content := range c.pagesContent {
+		page := Page{URL: url, Content: content, Edges: slices.Clone(c.edges[url])}
+		if r, ok := c.pageResults[url]; ok {
+			if r.Noindex {
+				continue
+			}
+			result := *r
+			page.Depth = result.Depth
+			page.Result = &result
+		}
+		pages = append(pages, page)
+	}
+	slices.SortFunc(pages, func(a, b Page) int {
+		return strings.Compare(a.URL, b.URL)
+	})
+	return pages
+}
+
+// WritePages writes pages as JSON lines, the format LoadPages reads back.
+func WritePages(w io.Writer, pages []Page) error {
+	enc := json.NewEncoder(w)
+	for _, page := range pages {
+		if err := enc.Encode(page); err != nil {
+			return fmt.Errorf("failed to write pages: %w", err)
+		}
+	}
+	return nil
+}
+
+// LoadPages reads a file written by WritePages, or the finished pages of a StateDir.
+func LoadPages(path string) ([]Page, error) {
+	info, err := os.Stat(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read pages: %w", err)
+	}
+	if info.IsDir() {
+		path = filepath.Join(path, pagesFile)
+		if _, err := os.Stat(path); err != nil {
+			return nil, fmt.Errorf("failed to read pages: %w", err)
+		}
+	}
+	return readLines[Page](path)
+}
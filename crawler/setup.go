@@ -3,29 +3,146 @@ package crawler
 import (
 	"regexp"
 	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/gtsteffaniak/html-web-crawler/browser"
 )
 
 type Crawler struct {
-	Threads   int
-	Timeout   int
-	MaxDepth  int
-	MaxLinks  int
+	Threads  int
+	Timeout  int
+	MaxDepth int
+	MaxLinks int
+	// Order is the crawl order when Frontier is nil: "bfs" (default), "dfs" or "priority"
+	Order string
+	// Frontier replaces the built-in crawl orders with a custom queue
+	Frontier Frontier
+	// Score ranks discovered links for the priority order, defaults to favouring shallow links matching the selectors
+	Score     ScoreFunc
 	SearchAny []string
 	SearchAll []string
 	Selectors Selectors
-	JsDepth   int
+	// Normalizer canonicalizes every URL before it is checked against visited pages. Pages are
+	// still fetched from the url as it was linked, the normalized form only identifies them
+	Normalizer Normalizer
+	// Sitemaps seeds the crawl with the pages in the sitemaps of each seed's site,
+	// SitemapSince leaves out pages last modified before it
+	Sitemaps     bool
+	SitemapSince time.Time
+	// Feeds crawls the entries of RSS and Atom feeds given as seeds, or advertised
+	// by seed pages, as depth 1 pages with the entry on their result
+	Feeds   bool
+	JsDepth int
+	// JsAuto renders pages past JsDepth with javascript only when they look client rendered
+	JsAuto bool
+	// BrowserWSURL connects to a running chrome DevTools endpoint instead of launching one
+	BrowserWSURL string
+	// BlockResources and BlockURLs skip matching requests when rendering javascript
+	BlockResources []string
+	BlockURLs      []string
+	Captures       Captures
+	// UserAgents are sent in turn with every request, Go's default when empty
+	UserAgents []string
+	// Headers are added to every request, including pages rendered with javascript
+	Headers map[string]string
+	// Jar keeps the cookies set during the crawl and shares them with the browser,
+	// CookieFile loads it from a Netscape cookies.txt file and saves it back after the crawl
+	Jar        *CookieJar
+	CookieFile string
+	// Auth holds the credentials sent to each domain and its subdomains
+	Auth map[string]Credentials
+	// Login logs in through a form before the crawl, its session is kept in Jar
+	Login *FormLogin
+	// Proxies are http, https or socks5 proxy urls used in turn, a proxy that can't
+	// be reached is skipped until it recovers. ProxyRules send a domain and its
+	// subdomains through a specific proxy, or "direct" to bypass the proxies. Proxies with
+	// credentials can't be used when javascript is rendered by a launched chrome
+	Proxies    []string
+	ProxyRules map[string]string
+	// MaxBodySize stops reading pages larger than this many bytes, 0 for no limit
+	MaxBodySize int64
+	// ContentTypes are the media types parsed for links, other pages are skipped
+	// without reading them. Every type is parsed when empty
+	ContentTypes []string
+	// ProbeHead sends HEAD before fetching a page, so pages that would be skipped
+	// are never downloaded or rendered
+	ProbeHead bool
+	// MaxRedirects is how many redirects are followed for a request before giving up
+	MaxRedirects int
+	// LinkSources are the places links are also taken from besides <a href>: "refresh"
+	// (meta refresh), "canonical" (marks the canonical page visited and skips pages
+	// duplicating a crawled one), "pagination" (link rel next and prev), "area", "iframe" and "frame"
+	LinkSources []string
+	// RobotsDirectives honors noindex and nofollow in robots meta tags and X-Robots-Tag
+	// headers, and doesn't follow links with rel nofollow, ugc or sponsored. Noindex pages
+	// are fetched, and their links followed, but left out of the results
+	RobotsDirectives bool
+	// Cache keeps responses between requests and crawls, reusing them while their
+	// Cache-Control or Expires headers allow and revalidating them after. CacheDir opens
+	// a DiskCache there when Cache is nil. With CacheTTL set every response but server
+	// errors and 401/403 is kept and reused for that long whatever its headers say. Set-Cookie
+	// headers aren't stored. Pages rendered with javascript aren't cached
+	Cache    Cache
+	CacheDir string
+	CacheTTL time.Duration
+	// Record writes every request the crawl sends and its response to a cassette file,
+	// Replay answers requests from one instead of the network. With both set to the same
+	// file, requests missing from it are fetched and added. Pages rendered with javascript
+	// are neither recorded nor replayed
+	Record string
+	Replay string
+	// RecordGraph keeps every link found on crawled pages for Graph. They are saved to StateDir
+	// and ValidatorsFile too, so resumed crawls and pages unchanged since a crawl that recorded
+	// the graph keep their links
+	RecordGraph bool
+	// StateDir checkpoints the crawl to disk, Resume continues the crawl saved there
+	StateDir string
+	Resume   bool
+	// ValidatorsFile keeps ETag, Last-Modified and content hashes between crawls so later
+	// crawls report pages as new, changed, unchanged or gone. Pages are gone when they answer
+	// 404 or 410, or when a crawl that ran to the end (not stopped or cut short by MaxLinks)
+	// no longer reaches them
+	ValidatorsFile string
+	// OnlyChanged leaves unchanged pages out of the Crawl and Collect output. Only then are
+	// conditional requests sent, unchanged pages are otherwise downloaded for their content
+	OnlyChanged bool
 	// private fields
 	pagesContent   map[string]string
+	pageResults    map[string]*PageResult
+	jsHosts        map[string]bool      // per host javascript decisions made by JsAuto
+	feedEntries    map[string]FeedEntry // feed entries found by Feeds, by page url
+	feedPages      map[string]bool      // seed pages whose advertised feeds are read by Feeds
+	links          map[string]string    // urls as they were linked, by normalized url, when they differ
+	browser        *browser.Browser
+	queue          *queue            // frontier of the running crawl
+	state          *state            // checkpoint files when StateDir is set
+	validators     *validators       // page validators when ValidatorsFile is set
+	linkCheck      *linkCheck        // links found by Check
+	proxies        *proxyPool        // proxies when Proxies or ProxyRules are set
+	edges          map[string][]Edge // links found when RecordGraph is set, by page
+	stats          Stats             // pages and links left out by robots directives, cache hits and misses
+	cache          Cache             // opened from Cache or CacheDir on the first request
+	tape           *tape             // cassettes for Record and Replay
+	stopped        atomic.Bool       // set by Stop
+	requests       atomic.Uint64     // requests sent, for user agent rotation
 	regexPatterns  []regexp.Regexp
 	collectedItems []string
 	errors         []error
 	mutex          sync.Mutex
-	wg             sync.WaitGroup
-	semaphore      chan struct{} // Shared semaphore for concurrency control
 	mode           string
 	Silent         bool
 }
 
+// Captures control the screenshots and PDFs taken of javascript rendered pages.
+type Captures struct {
+	Screenshot     string // "png" or "jpeg", empty to disable
+	PDF            bool
+	OutputDir      string
+	ViewportWidth  int
+	ViewportHeight int
+}
+
 type Selectors struct {
 	Collections      []string
 	Classes          []string
@@ -40,15 +157,39 @@ type Selectors struct {
 
 func NewCrawler() *Crawler {
 	return &Crawler{
-		pagesContent: make(map[string]string),
-		Threads:      1,  // single threaded by default
-		Timeout:      10, // 10 seconds
-		MaxDepth:     2,  // default is provided urls and follow any links on that page
-		MaxLinks:     0,  // unlimited
-		JsDepth:      0,  // javascript disabled by default
-		Silent:       false,
-		SearchAny:    []string{},
-		SearchAll:    []string{},
+		pagesContent:   make(map[string]string),
+		pageResults:    make(map[string]*PageResult),
+		jsHosts:        make(map[string]bool),
+		feedEntries:    make(map[string]FeedEntry),
+		feedPages:      make(map[string]bool),
+		links:          make(map[string]string),
+		Threads:        1,     // single threaded by default
+		Timeout:        10,    // 10 seconds
+		MaxDepth:       2,     // default is provided urls and follow any links on that page
+		MaxLinks:       0,     // unlimited
+		Order:          "bfs", // shallowest pages first
+		JsDepth:        0,     // javascript disabled by default
+		Silent:         false,
+		SearchAny:      []string{},
+		SearchAll:      []string{},
+		Normalizer:     NewNormalizer(),
+		UserAgents:     []string{},
+		Headers:        make(map[string]string),
+		Jar:            NewCookieJar(),
+		Auth:           make(map[string]Credentials),
+		MaxBodySize:    10 << 20, // 10 MiB
+		ContentTypes:   []string{"text/html", "application/xhtml+xml"},
+		MaxRedirects:   10,
+		LinkSources:    []string{},
+		Proxies:        []string{},
+		ProxyRules:     make(map[string]string),
+		BlockResources: []string{},
+		BlockURLs:      []string{},
+		Captures: Captures{
+			OutputDir:      "captures",
+			ViewportWidth:  1920,
+			ViewportHeight: 1080,
+		},
 		Selectors: Selectors{
 			ExcludedUrls:     []string{},
 			Collections:      []string{"html"},
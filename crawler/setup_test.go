@@ -13,14 +13,38 @@ func TestNewCrawler(t *testing.T) {
 		{
 			name: "Test New Crawler",
 			want: &Crawler{
-				pagesContent: make(map[string]string),
-				Threads:      1,  // single threaded by default
-				Timeout:      10, // 10 seconds
-				MaxDepth:     2,  // default is provided urls and follow any links on that page
-				MaxLinks:     0,  // unlimited
-				JsDepth:      0,  // javascript disabled by default
-				SearchAny:    []string{},
-				SearchAll:    []string{},
+				pagesContent:   make(map[string]string),
+				pageResults:    make(map[string]*PageResult),
+				jsHosts:        make(map[string]bool),
+				feedEntries:    make(map[string]FeedEntry),
+				feedPages:      make(map[string]bool),
+				links:          make(map[string]string),
+				Threads:        1,     // single threaded by default
+				Timeout:        10,    // 10 seconds
+				MaxDepth:       2,     // default is provided urls and follow any links on that page
+				MaxLinks:       0,     // unlimited
+				Order:          "bfs", // shallowest pages first
+				JsDepth:        0,     // javascript disabled by default
+				SearchAny:      []string{},
+				SearchAll:      []string{},
+				Normalizer:     NewNormalizer(),
+				UserAgents:     []string{},
+				Headers:        make(map[string]string),
+				Jar:            NewCookieJar(),
+				Auth:           make(map[string]Credentials),
+				MaxBodySize:    10 << 20, // 10 MiB
+				ContentTypes:   []string{"text/html", "application/xhtml+xml"},
+				MaxRedirects:   10,
+				LinkSources:    []string{},
+				Proxies:        []string{},
+				ProxyRules:     make(map[string]string),
+				BlockResources: []string{},
+				BlockURLs:      []string{},
+				Captures: Captures{
+					OutputDir:      "captures",
+					ViewportWidth:  1920,
+					ViewportHeight: 1080,
+				},
 				Selectors: Selectors{
 					ExcludedUrls:     []string{},
 					Collections:      []string{"html"},
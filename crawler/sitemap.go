@@ -0,0 +1,334 @@
+package crawler
+
+import (
+	"bufio"
+	"bytes"
+	"compress/gzip"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"time"
+)
+
+const (
+	// maxSitemapSize is the largest uncompressed sitemap the protocol allows.
+	maxSitemapSize = 50 * 1024 * 1024
+	// maxSitemapNesting limits how deep sitemap indexes are followed.
+	maxSitemapNesting = 3
+)
+
+// lastmodFormats are the W3C datetime forms allowed in sitemap lastmod.
+var lastmodFormats = []string{time.RFC3339, "2006-01-02T15:04Z07:00", "2006-01-02", "2006-01", "2006"}
+
+// SitemapURL is a page listed in a sitemap.
+type SitemapURL struct {
+	Loc     string
+	LastMod time.Time // zero when the sitemap doesn't say
+}
+
+// sitemapXML decodes both urlsets and sitemap indexes.
+type sitemapXML struct {
+	XMLName xml.Name
+	URLs    []struct {
+		Loc     string `xml:"loc"`
+		LastMod string `xml:"lastmod"`
+	} `xml:"url"`
+	Sitemaps []struct {
+		Loc     string `xml:"loc"`
+		LastMod string `xml:"lastmod"`
+	} `xml:"sitemap"`
+}
+
+// DiscoverSitemaps returns the sitemaps of a site listed in its robots.txt, or /sitemap.xml when there are none.
+func (c *Crawler) DiscoverSitemaps(siteURL string) []string {
+	u, err := url.Parse(siteURL)
+	if err != nil || u.Host == "" {
+		return nil
+	}
+	root := u.Scheme + "://" + u.Host
+	sitemaps := []string{}
+	if body, err := c.fetchBody(root + "/robots.txt"); err == nil {
+		scanner := bufio.NewScanner(bytes.NewReader(body))
+		for scanner.Scan() {
+			key, value, ok := strings.Cut(scanner.Text(), ":")
+			if ok && strings.EqualFold(strings.TrimSpace(key), "sitemap") {
+				if loc := strings.TrimSpace(value); loc != "" && !slices.Contains(sitemaps, loc) {
+					sitemaps = append(sitemaps, loc)
+				}
+			}
+		}
+	}
+	if len(sitemaps) == 0 {
+		sitemaps = append(sitemaps, root+"/sitemap.xml")
+	}
+	return sitemaps
+}
+
+// ReadSitemap returns the pages listed in a sitemap, following sitemap indexes and
+// decompressing gzip sitemaps. Pages and nested sitemaps last modified before since are left out.
+func (c *Crawler) ReadSitemap(sitemapURL string, since time.Time) ([]SitemapURL, error) {
+	return c.readSitemap(sitemapURL, since, 0, map[string]bool{})
+}
+
+func (c *Crawler) readSitemap(sitemapURL string, since time.Time, nesting int, seen map[string]bool) ([]SitemapURL, error) {
+	if seen[sitemapURL] {
+		return nil, nil
+	}
+	seen[sitemapURL] = true
+	body, err := c.fetchBody(sitemapURL)
+	if err != nil {
+		return nil, err
+	}
+	// sitemap.xml.gz files are served as is, not with a gzip content encoding
+	if len(body) > 2 && body[0] == 0x1f && body[1] == 0x8b {
+		zr, err := gzip.NewReader(bytes.NewReader(body))
+		if err != nil {
+			return nil, fmt.Errorf("invalid gzip sitemap %s: %w", sitemapURL, err)
+		}
+		body, err = io.ReadAll(io.LimitReader(zr, maxSitemapSize))
+		if err != nil {
+			return nil, fmt.Errorf("invalid gzip sitemap %s: %w", sitemapURL, err)
+		}
+	}
+	var doc sitemapXML
+	if err := xml.Unmarshal(body, &doc); err != nil {
+		return nil, fmt.Errorf("invalid sitemap %s: %w", sitemapURL, err)
+	}
+	if doc.XMLName.Local != "urlset" && doc.XMLName.Local != "sitemapindex" {
+		return nil, fmt.Errorf("invalid sitemap %s: unexpected <%s> element", sitemapURL, doc.XMLName.Local)
+	}
+	urls := []SitemapURL{}
+	for _, u := range doc.URLs {
+		entry := SitemapURL{Loc: strings.TrimSpace(u.Loc), LastMod: parseLastMod(u.LastMod)}
+		if entry.Loc == "" || !since.IsZero() && !entry.LastMod.IsZero() && entry.LastMod.Before(since) {
+			continue
+		}
+		urls = append(urls, entry)
+	}
+	if nesting >= maxSitemapNesting {
+		return urls, nil
+	}
+	for _, s := range doc.Sitemaps {
+		loc := strings.TrimSpace(s.Loc)
+		lastMod := parseLastMod(s.LastMod)
+		if loc == "" || !since.IsZero() && !lastMod.IsZero() && lastMod.Before(since) {
+			continue
+		}
+		nested, err := c.readSitemap(loc, since, nesting+1, seen)
+		if err != nil {
+			// one broken sitemap in an index doesn't spoil the others
+			if !c.Silent {
+				fmt.Printf("Warning: %v\n", err)
+			}
+			continue
+		}
+		urls = append(urls, nested...)
+	}
+	return urls, nil
+}
+
+// sitemapSeeds reads the sitemaps of every seed's site and returns the pages to crawl,
+// most recently modified first, that match Selectors.Domains and UrlPatterns.
+func (c *Crawler) sitemapSeeds(seeds []string) []string {
+	sites := []string{}
+	for _, seed := range seeds {
+		if u, err := url.Parse(seed); err == nil && u.Host != "" && !slices.Contains(sites, u.Scheme+"://"+u.Host) {
+			sites = append(sites, u.Scheme+"://"+u.Host)
+		}
+	}
+	found := []SitemapURL{}
+	for _, site := range sites {
+		count := 0
+		for _, sitemap := range c.DiscoverSitemaps(site) {
+			urls, err := c.ReadSitemap(sitemap, c.SitemapSince)
+			if err != nil {
+				if !c.Silent {
+					fmt.Printf("Warning: %v\n", err)
+				}
+				continue
+			}
+			for _, u := range urls {
+				if c.validDomainCheck(u.Loc) && c.linkTextCheck(u.Loc, "") {
+					found = append(found, u)
+					count++
+				}
+			}
+		}
+		if !c.Silent {
+			fmt.Printf("found %d pages in the sitemaps of %s\n", count, site)
+		}
+	}
+	slices.SortStableFunc(found, func(a, b SitemapURL) int {
+		return b.LastMod.Compare(a.LastMod)
+	})
+	pages := make([]string, len(found))
+	for i, u := range found {
+		pages[i] = u.Loc
+	}
+	return pages
+}
+
+// fetchBody downloads a small resource such as robots.txt or a sitemap.
+func (c *Crawler) fetchBody(resourceURL string) ([]byte, error) {
+	req, err := http.NewRequest(http.MethodGet, resourceURL, nil)
+	if err != nil {
+		return nil, fmt.Errorf("invalid request for %s: %w", resourceURL, err)
+	}
+	resp, err := c.do(req)
+	if err != nil {
+		return nil, fmt.Errorf("network error fetching %s: %w", resourceURL, err)
+	}
+	defer func() {
+		_ = resp.Body.Close()
+	}()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("HTTP %d %s for %s", resp.StatusCode, resp.Status, resourceURL)
+	}
+	return io.ReadAll(io.LimitReader(resp.Body, maxSitemapSize))
+}
+
+// parseLastMod parses a sitemap lastmod, returning the zero time when it is missing or invalid.
+func parseLastMod(value string) time.Time {
+	value = strings.TrimSpace(value)
+	for _, layout := range lastmodFormats {
+		if t, err := time.Parse(layout, value); err == nil {
+			return t
+		}
+	}
+	return time.Time{}
+}
+
+const (
+	// maxSitemapURLs is the most URLs the protocol allows in one sitemap file.
+	maxSitemapURLs = 50_000
+	// sitemapNamespace is the xml namespace of sitemaps and sitemap indexes.
+	sitemapNamespace = "http://www.sitemaps.org/schemas/sitemap/0.9"
+)
+
+// SitemapOptions control the sitemaps written by WriteSitemaps.
+type SitemapOptions struct {
+	// BaseURL is where the sitemap files are served from, used for the locs of a sitemap index.
+	// Defaults to the site of the first page.
+	BaseURL string
+	// ChangeFreq and Priority add changefreq and priority derived from page depth.
+	ChangeFreq bool
+	Priority   bool
+	// MaxURLs is the number of URLs per sitemap file, defaults to 50,000.
+	MaxURLs int
+}
+
+type urlsetXML struct {
+	XMLName xml.Name     `xml:"urlset"`
+	Xmlns   string       `xml:"xmlns,attr"`
+	URLs    []sitemapLoc `xml:"url"`
+}
+
+type sitemapIndexXML struct {
+	XMLName  xml.Name     `xml:"sitemapindex"`
+	Xmlns    string       `xml:"xmlns,attr"`
+	Sitemaps []sitemapLoc `xml:"sitemap"`
+}
+
+type sitemapLoc struct {
+	Loc        string `xml:"loc"`
+	LastMod    string `xml:"lastmod,omitempty"`
+	ChangeFreq string `xml:"changefreq,omitempty"`
+	Priority   string `xml:"priority,omitempty"`
+}
+
+// WriteSitemaps writes sitemap.xml to dir for the pages that were fetched successfully and
+// not skipped, listed by the url they were served from rather than their normalized url.
+// Past MaxURLs pages it writes sitemap-1.xml, sitemap-2.xml, ... and makes sitemap.xml an index of them.
+// It returns the files written.
+func WriteSitemaps(dir string, results []PageResult, opts SitemapOptions) ([]string, error) {
+	if opts.MaxURLs <= 0 || opts.MaxURLs > maxSitemapURLs {
+		opts.MaxURLs = maxSitemapURLs
+	}
+	urls := []sitemapLoc{}
+	for _, r := range results {
+		if r.Error != "" || r.Skipped != "" || r.Status == "gone" {
+			continue
+		}
+		loc := sitemapLoc{Loc: r.URL}
+		if r.FinalURL != "" {
+			loc.Loc = r.FinalURL
+		}
+		if t, err := http.ParseTime(r.LastModified); err == nil {
+			loc.LastMod = t.UTC().Format(time.RFC3339)
+		}
+		if opts.ChangeFreq {
+			loc.ChangeFreq = depthChangeFreq(r.Depth)
+		}
+		if opts.Priority {
+			loc.Priority = depthPriority(r.Depth)
+		}
+		urls = append(urls, loc)
+	}
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return nil, fmt.Errorf("failed to create sitemap directory: %w", err)
+	}
+	index := filepath.Join(dir, "sitemap.xml")
+	if len(urls) <= opts.MaxURLs {
+		return []string{index}, writeXML(index, urlsetXML{Xmlns: sitemapNamespace, URLs: urls})
+	}
+	baseURL := strings.TrimRight(opts.BaseURL, "/")
+	if baseURL == "" {
+		if u, err := url.Parse(urls[0].Loc); err == nil {
+			baseURL = u.Scheme + "://" + u.Host
+		}
+	}
+	files := []string{index}
+	sitemaps := []sitemapLoc{}
+	for part := 0; part*opts.MaxURLs < len(urls); part++ {
+		chunk := urls[part*opts.MaxURLs : min((part+1)*opts.MaxURLs, len(urls))]
+		name := fmt.Sprintf("sitemap-%d.xml", part+1)
+		file := filepath.Join(dir, name)
+		if err := writeXML(file, urlsetXML{Xmlns: sitemapNamespace, URLs: chunk}); err != nil {
+			return nil, err
+		}
+		files = append(files, file)
+		sitemap := sitemapLoc{Loc: baseURL + "/" + name}
+		for _, u := range chunk {
+			sitemap.LastMod = max(sitemap.LastMod, u.LastMod)
+		}
+		sitemaps = append(sitemaps, sitemap)
+	}
+	return files, writeXML(index, sitemapIndexXML{Xmlns: sitemapNamespace, Sitemaps: sitemaps})
+}
+
+// depthChangeFreq guesses how often a page changes, pages close to the start change most.
+func depthChangeFreq(depth int) string {
+	switch {
+	case depth <= 1:
+		return "daily"
+	case depth == 2:
+		return "weekly"
+	default:
+		return "monthly"
+	}
+}
+
+// depthPriority gives the start pages priority 1.0 and 0.2 less for each level below, down to 0.1.
+func depthPriority(depth int) string {
+	priority := max(1.0-0.2*float64(max(depth-1, 0)), 0.1)
+	return fmt.Sprintf("%.1f", priority)
+}
+
+// writeXML writes v as an xml document.
+func writeXML(path string, v any) error {
+	data, err := xml.MarshalIndent(v, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to write %s: %w", path, err)
+	}
+	data = append([]byte(xml.Header), append(data, '\n')...)
+	if err := os.WriteFile(path, data, 0o644); err != nil {
+		return fmt.Errorf("failed to write %s: %w", path, err)
+	}
+	return nil
+}
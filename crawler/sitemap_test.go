@@ -0,0 +1,158 @@
+package crawler
+
+import (
+	"bytes"
+	"compress/gzip"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// sitemapSite serves a site with a sitemap index, closed when the test finishes.
+func sitemapSite(t *testing.T, robots bool) *httptest.Server {
+	t.Helper()
+	var server *httptest.Server
+	server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		site := func(s string) []byte { return bytes.ReplaceAll([]byte(s), []byte("SITE"), []byte(server.URL)) }
+		switch r.URL.Path {
+		case "/robots.txt":
+			if !robots {
+				http.NotFound(w, r)
+				return
+			}
+			_, _ = w.Write(site("User-agent: *\nDisallow:\nSitemap: SITE/sitemap_index.xml\n"))
+		case "/sitemap_index.xml", "/sitemap.xml":
+			_, _ = w.Write(site(`<?xml version="1.0" encoding="UTF-8"?>
+<sitemapindex xmlns="http://www.sitemaps.org/schemas/sitemap/0.9">
+  <sitemap><loc>SITE/pages.xml</loc></sitemap>
+  <sitemap><loc>SITE/blog.xml.gz</loc><lastmod>2026-05-01</lastmod></sitemap>
+  <sitemap><loc>SITE/missing.xml</loc></sitemap>
+</sitemapindex>`))
+		case "/pages.xml":
+			_, _ = w.Write(site(`<urlset xmlns="http://www.sitemaps.org/schemas/sitemap/0.9">
+  <url><loc>SITE/about</loc></url>
+  <url><loc>https://other.example/partner</loc></url>
+</urlset>`))
+		case "/blog.xml.gz":
+			zw := gzip.NewWriter(w)
+			_, _ = zw.Write(site(`<?xml version="1.0" encoding="UTF-8"?>
+<urlset xmlns="http://www.sitemaps.org/schemas/sitemap/0.9">
+  <url><loc>SITE/blog/new</loc><lastmod>2026-05-01T10:00:00+00:00</lastmod></url>
+  <url><loc>SITE/blog/old</loc><lastmod>2019-01-01</lastmod></url>
+</urlset>`))
+			_ = zw.Close()
+		case "/":
+			_, _ = w.Write([]byte(`<p>home without links</p>`))
+		default:
+			_, _ = w.Write([]byte(`<p>` + r.URL.Path + `</p>`))
+		}
+	}))
+	t.Cleanup(server.Close)
+	return server
+}
+
+func TestDiscoverSitemaps(t *testing.T) {
+	c := NewCrawler()
+	c.Silent = true
+	withRobots := sitemapSite(t, true)
+	assert.Equal(t, []string{withRobots.URL + "/sitemap_index.xml"}, c.DiscoverSitemaps(withRobots.URL+"/some/page"))
+	withoutRobots := sitemapSite(t, false)
+	assert.Equal(t, []string{withoutRobots.URL + "/sitemap.xml"}, c.DiscoverSitemaps(withoutRobots.URL))
+}
+
+func TestReadSitemap(t *testing.T) {
+	server := sitemapSite(t, true)
+	c := NewCrawler()
+	c.Silent = true
+
+	urls, err := c.ReadSitemap(server.URL+"/sitemap_index.xml", time.Time{})
+	assert.NoError(t, err)
+	locs := []string{}
+	for _, u := range urls {
+		locs = append(locs, u.Loc)
+	}
+	assert.Equal(t, []string{server.URL + "/about", "https://other.example/partner", server.URL + "/blog/new", server.URL + "/blog/old"}, locs)
+	assert.True(t, urls[0].LastMod.IsZero())
+	assert.True(t, urls[2].LastMod.Equal(time.Date(2026, 5, 1, 10, 0, 0, 0, time.UTC)))
+	assert.True(t, urls[3].LastMod.Equal(time.Date(2019, 1, 1, 0, 0, 0, 0, time.UTC)))
+
+	urls, err = c.ReadSitemap(server.URL+"/sitemap_index.xml", time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC))
+	assert.NoError(t, err)
+	assert.Len(t, urls, 3, "pages modified before since are skipped")
+
+	_, err = c.ReadSitemap(server.URL+"/missing.xml", time.Time{})
+	assert.Error(t, err)
+}
+
+func TestCrawlSitemaps(t *testing.T) {
+	server := sitemapSite(t, true)
+	c := NewCrawler()
+	c.Silent = true
+	c.MaxDepth = 1
+	c.Sitemaps = true
+	c.Selectors.Domains = []string{getDomain(server.URL)}
+	c.Selectors.UrlPatterns = []string{"/blog/"}
+	results, err := c.Crawl(server.URL)
+	assert.NoError(t, err)
+	assert.Contains(t, results, server.URL+"/blog/new")
+	assert.Contains(t, results, server.URL+"/blog/old")
+	assert.NotContains(t, results, server.URL+"/about", "url patterns apply to sitemap pages")
+	assert.NotContains(t, results, "https://other.example/partner", "domains apply to sitemap pages")
+}
+
+func TestWriteSitemaps(t *testing.T) {
+	results := []PageResult{
+		{URL: "https://example.com/", Depth: 1, LastModified: "Mon, 04 May 2026 09:30:00 GMT"},
+		{URL: "https://example.com/a?x=1&y=2", Depth: 2},
+		{URL: "https://example.com/docs", FinalURL: "https://example.com/docs/", Depth: 3},
+		{URL: "https://example.com/broken", Depth: 2, Error: "HTTP 404"},
+		{URL: "https://example.com/logo.png", Depth: 2, Skipped: "content type image/png is not parsed"},
+		{URL: "https://example.com/moved", FinalURL: "https://other.com/", Depth: 2, Skipped: "redirected outside the crawled domains"},
+	}
+
+	dir := t.TempDir()
+	files, err := WriteSitemaps(dir, results, SitemapOptions{ChangeFreq: true, Priority: true})
+	assert.NoError(t, err)
+	assert.Equal(t, []string{filepath.Join(dir, "sitemap.xml")}, files)
+	data, err := os.ReadFile(files[0])
+	assert.NoError(t, err)
+	// pages are listed by the url they were served from, skipped and failed pages are left out
+	assert.Equal(t, `<?xml version="1.0" encoding="UTF-8"?>
+<urlset xmlns="http://www.sitemaps.org/schemas/sitemap/0.9">
+  <url>
+    <loc>https://example.com/</loc>
+    <lastmod>2026-05-04T09:30:00Z</lastmod>
+    <changefreq>daily</changefreq>
+    <priority>1.0</priority>
+  </url>
+  <url>
+    <loc>https://example.com/a?x=1&amp;y=2</loc>
+    <changefreq>weekly</changefreq>
+    <priority>0.8</priority>
+  </url>
+  <url>
+    <loc>https://example.com/docs/</loc>
+    <changefreq>monthly</changefreq>
+    <priority>0.6</priority>
+  </url>
+</urlset>
+`, string(data))
+
+	// the written sitemaps can be read back, split into an index past MaxURLs
+	dir = t.TempDir()
+	server := httptest.NewServer(http.FileServer(http.Dir(dir)))
+	defer server.Close()
+	files, err = WriteSitemaps(dir, results, SitemapOptions{MaxURLs: 2, BaseURL: server.URL})
+	assert.NoError(t, err)
+	assert.Len(t, files, 3)
+	c := NewCrawler()
+	urls, err := c.ReadSitemap(server.URL+"/sitemap.xml", time.Time{})
+	assert.NoError(t, err)
+	assert.Len(t, urls, 3)
+	assert.True(t, urls[0].LastMod.Equal(time.Date(2026, 5, 4, 9, 30, 0, 0, time.UTC)))
+}
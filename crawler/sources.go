@@ -0,0 +1,95 @@
+package crawler
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+
+	"golang.org/x/net/html"
+)
+
+// sourceAnchor returns the link of an element enabled in LinkSources. Meta refresh, canonical
+// and pagination links describe the whole page, so they are found outside the selected elements too.
+func (c *Crawler) sourceAnchor(n *html.Node, inTargetElement bool) (anchor, bool) {
+	switch n.Data {
+	case "meta":
+		if !c.followsSource("refresh") || !strings.EqualFold(attrValue(n, "http-equiv"), "refresh") {
+			return anchor{}, false
+		}
+		target := refreshURL(attrValue(n, "content"))
+		return anchor{href: target, source: "refresh"}, target != ""
+	case "link":
+		href := strings.TrimSpace(attrValue(n, "href"))
+		rel := strings.Fields(strings.ToLower(attrValue(n, "rel")))
+		if href == "" {
+			return anchor{}, false
+		}
+		if slices.Contains(rel, "canonical") && c.followsSource("canonical") {
+			return anchor{href: href, rel: "canonical", source: "canonical"}, true
+		}
+		if (slices.Contains(rel, "next") || slices.Contains(rel, "prev")) && c.followsSource("pagination") {
+			return anchor{href: href, rel: strings.Join(rel, " "), source: "pagination"}, true
+		}
+	case "area":
+		if inTargetElement && c.followsSource("area") && hasAttr(n, "href") {
+			return anchor{href: attrValue(n, "href"), text: attrValue(n, "alt"), rel: attrValue(n, "rel"), source: "area"}, true
+		}
+	case "iframe", "frame":
+		src := strings.TrimSpace(attrValue(n, "src"))
+		if inTargetElement && c.followsSource(n.Data) && src != "" && src != "about:blank" {
+			return anchor{href: src, text: attrValue(n, "title"), source: n.Data}, true
+		}
+	}
+	return anchor{}, false
+}
+
+// followsSource reports whether links are taken from a source in LinkSources.
+func (c *Crawler) followsSource(source string) bool {
+	return slices.Contains(c.LinkSources, source)
+}
+
+// refreshURL returns the url of a meta refresh content value such as "5; url=/next".
+func refreshURL(content string) string {
+	_, target, found := strings.Cut(content, ";")
+	if !found {
+		return ""
+	}
+	target = strings.TrimSpace(target)
+	if len(target) < 4 || !strings.EqualFold(target[:3], "url") {
+		return ""
+	}
+	target = strings.TrimSpace(target[3:])
+	target, found = strings.CutPrefix(target, "=")
+	if !found {
+		return ""
+	}
+	return strings.Trim(strings.TrimSpace(target), `"'`)
+}
+
+// canonicalize handles the canonical url a page declares. The canonical page is marked visited,
+// since this page stands in for it, and a page whose canonical url was crawled already is a
+// duplicate, so it returns false and the page's links are not followed.
+func (c *Crawler) canonicalize(pageURL string, anchors []anchor) bool {
+	i := slices.IndexFunc(anchors, func(a anchor) bool { return a.source == "canonical" })
+	if i < 0 {
+		return true
+	}
+	canonical := c.normalize(toAbsoluteURL(c.finalURL(pageURL), anchors[i].href))
+	if canonical == pageURL || canonical == c.normalize(c.finalURL(pageURL)) || !c.validDomainCheck(canonical) {
+		return true
+	}
+	c.recordPage(pageURL, func(r *PageResult) {
+		r.Canonical = canonical
+	})
+	if c.claim(canonical) {
+		return true
+	}
+	reason := "duplicate of " + canonical
+	if !c.Silent {
+		fmt.Printf("skipping %s: %s\n", pageURL, reason)
+	}
+	c.recordPage(pageURL, func(r *PageResult) {
+		r.Skipped = reason
+	})
+	return false
+}
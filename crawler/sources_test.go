@@ -0,0 +1,140 @@
+package crawler
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRefreshURL(t *testing.T) {
+	tests := []struct {
+		content string
+		want    string
+	}{
+		{"0; url=/next", "/next"},
+		{"5;URL='https://example.com/moved'", "https://example.com/moved"},
+		{`3; url = "page2.html"`, "page2.html"},
+		{"10", ""},
+		{"0; /next", ""},
+		{"", ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.content, func(t *testing.T) {
+			assert.Equal(t, tt.want, refreshURL(tt.content))
+		})
+	}
+}
+
+func TestLinkSources(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/html")
+		switch r.URL.Path {
+		case "/":
+			_, _ = w.Write([]byte(`<html><head>
+				<meta http-equiv="Refresh" content="30; url=/refreshed">
+				<link rel="next" href="/list?page=2"><link rel="stylesheet" href="/style.css">
+				</head><body><div class="main">
+				<a href="/anchor">anchor</a>
+				<map><area href="/area" alt="region"></map>
+				<iframe src="/embedded" title="widget"></iframe><iframe src="about:blank"></iframe>
+				</div><area href="/outside"></body></html>`))
+		case "/legacy":
+			_, _ = w.Write([]byte(`<frameset><frame src="/menu"><frame src="/content"></frameset>`))
+		default:
+			_, _ = w.Write([]byte(`page`))
+		}
+	}))
+	defer server.Close()
+
+	tests := []struct {
+		name    string
+		sources []string
+		classes []string
+		want    []string
+	}{
+		{"anchors only", []string{}, nil, []string{"/", "/anchor"}},
+		{"every source", []string{"refresh", "pagination", "area", "iframe"}, nil,
+			[]string{"/", "/anchor", "/area", "/embedded", "/list?page=2", "/outside", "/refreshed"}},
+		{"selected elements", []string{"refresh", "pagination", "area", "iframe"}, []string{"main"},
+			[]string{"/", "/anchor", "/area", "/embedded", "/list?page=2", "/refreshed"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := NewCrawler()
+			c.Silent = true
+			c.LinkSources = tt.sources
+			c.Selectors.Classes = tt.classes
+			_, err := c.Crawl(server.URL + "/")
+			assert.NoError(t, err)
+			assert.Equal(t, tt.want, crawledPaths(c, server.URL))
+		})
+	}
+
+	c := NewCrawler()
+	c.Silent = true
+	c.LinkSources = []string{"frame"}
+	_, err := c.Crawl(server.URL + "/legacy")
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"/content", "/legacy", "/menu"}, crawledPaths(c, server.URL))
+}
+
+func TestCanonical(t *testing.T) {
+	var mutex sync.Mutex
+	fetches := map[string]int{}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mutex.Lock()
+		fetches[r.URL.Path]++
+		mutex.Unlock()
+		w.Header().Set("Content-Type", "text/html")
+		switch r.URL.Path {
+		case "/":
+			_, _ = w.Write([]byte(`<a href="/a">a</a><a href="/a/print">print a</a><a href="/b/print">print b</a>`))
+		case "/a", "/a/print":
+			_, _ = w.Write([]byte(`<link rel="canonical" href="/a"><a href="/from-a">more</a>`))
+		case "/b/print":
+			_, _ = w.Write([]byte(`<link rel="canonical" href="../b"><a href="/from-b">more</a>`))
+		default:
+			_, _ = w.Write([]byte(`<link rel="canonical" href="">page`))
+		}
+	}))
+	defer server.Close()
+
+	c := NewCrawler()
+	c.Silent = true
+	c.MaxDepth = 3
+	c.LinkSources = []string{"canonical"}
+	_, err := c.Crawl(server.URL + "/")
+	assert.NoError(t, err)
+
+	results := map[string]PageResult{}
+	for _, r := range c.Results() {
+		results[strings.TrimPrefix(r.URL, server.URL)] = r
+	}
+	assert.Equal(t, server.URL+"/a", results["/a/print"].Canonical)
+	assert.Equal(t, "duplicate of "+server.URL+"/a", results["/a/print"].Skipped)
+	assert.Empty(t, results["/a"].Canonical)
+	assert.Empty(t, results["/a"].Skipped)
+	// /b is only known through its print version, which stands in for it
+	assert.Equal(t, server.URL+"/b", results["/b/print"].Canonical)
+	assert.Empty(t, results["/b/print"].Skipped)
+	assert.Equal(t, []string{"/", "/a", "/a/print", "/b/print", "/from-a", "/from-b"}, crawledPaths(c, server.URL))
+	mutex.Lock()
+	defer mutex.Unlock()
+	assert.Zero(t, fetches["/b"])
+	assert.Equal(t, 1, fetches["/from-a"])
+}
+
+// crawledPaths returns the paths of the pages a crawl fetched, sorted.
+func crawledPaths(c *Crawler, serverURL string) []string {
+	paths := []string{}
+	for _, r := range c.Results() {
+		paths = append(paths, strings.TrimPrefix(r.URL, serverURL))
+	}
+	slices.Sort(paths)
+	return paths
+}
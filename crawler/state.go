@@ -0,0 +1,235 @@
+package crawler
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+)
+
+const (
+	frontierFile = "frontier.jsonl"
+	pagesFile    = "pages.jsonl"
+)
+
+// state checkpoints a crawl to append-only files so it can be resumed.
+// frontier.jsonl has every queued entry and pages.jsonl every finished page,
+// a page is pending when it was queued but never finished.
+type state struct {
+	mutex    sync.Mutex
+	frontier *os.File
+	pages    *os.File
+	err      error // first write error
+}
+
+// openState opens the state files in dir, truncating them unless resuming.
+func openState(dir string, resume bool) (*state, error) {
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return nil, fmt.Errorf("failed to create state directory: %w", err)
+	}
+	frontier, err := openStateFile(filepath.Join(dir, frontierFile), resume)
+	if err != nil {
+		return nil, err
+	}
+	pages, err := openStateFile(filepath.Join(dir, pagesFile), resume)
+	if err != nil {
+		_ = frontier.Close()
+		return nil, err
+	}
+	return &state{frontier: frontier, pages: pages}, nil
+}
+
+// openStateFile opens a state file for appending. When resuming, a partially written last
+// line left by a crash is cut off, so the next record doesn't get glued onto it.
+func openStateFile(path string, resume bool) (*os.File, error) {
+	flags := os.O_CREATE | os.O_RDWR | os.O_APPEND
+	if !resume {
+		flags |= os.O_TRUNC
+	}
+	f, err := os.OpenFile(path, flags, 0o644)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open crawl state: %w", err)
+	}
+	if resume {
+		if err := trimPartialLine(f); err != nil {
+			_ = f.Close()
+			return nil, fmt.Errorf("failed to repair crawl state: %w", err)
+		}
+	}
+	return f, nil
+}
+
+// trimPartialLine truncates a file after its last newline.
+func trimPartialLine(f *os.File) error {
+	info, err := f.Stat()
+	if err != nil {
+		return err
+	}
+	buf := make([]byte, 4096)
+	for end := info.Size(); end > 0; {
+		n := min(int64(len(buf)), end)
+		if _, err := f.ReadAt(buf[:n], end-n); err != nil {
+			return err
+		}
+		if i := bytes.LastIndexByte(buf[:n], '\n'); i != -1 {
+			if size := end - n + int64(i) + 1; size < info.Size() {
+				return f.Truncate(size)
+			}
+			return nil
+		}
+		end -= n
+	}
+	return f.Truncate(0)
+}
+
+// queued records an entry added to the frontier.
+func (s *state) queued(entry Entry) {
+	s.append(s.frontier, entry)
+}
+
+// finished records a page that was crawled.
+func (s *state) finished(record Page) {
+	s.append(s.pages, record)
+}
+
+// append writes one json line, keeping the first error.
+func (s *state) append(f *os.File, v any) {
+	line, err := json.Marshal(v)
+	if err == nil {
+		s.mutex.Lock()
+		_, err = f.Write(append(line, '\n'))
+		s.mutex.Unlock()
+	}
+	if err != nil {
+		s.mutex.Lock()
+		if s.err == nil {
+			s.err = fmt.Errorf("failed to write crawl state: %w", err)
+		}
+		s.mutex.Unlock()
+	}
+}
+
+// close flushes the state files and returns the first error seen.
+func (s *state) close() error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	err := errors.Join(s.frontier.Sync(), s.pages.Sync(), s.frontier.Close(), s.pages.Close())
+	if s.err != nil {
+		return s.err
+	}
+	return err
+}
+
+// loadState reads the finished pages and pending frontier entries of a previous crawl.
+// A partially written last line, left by a crash, is ignored.
+func loadState(dir string) ([]Page, []Entry, error) {
+	pages, err := readLines[Page](filepath.Join(dir, pagesFile))
+	if err != nil {
+		return nil, nil, err
+	}
+	entries, err := readLines[Entry](filepath.Join(dir, frontierFile))
+	if err != nil {
+		return nil, nil, err
+	}
+	finished := make(map[string]bool, len(pages))
+	for _, p := range pages {
+		finished[p.URL] = true
+	}
+	pending := []Entry{}
+	for _, e := range entries {
+		if !finished[e.URL] {
+			pending = append(pending, e)
+		}
+	}
+	return pages, pending, nil
+}
+
+// readLines decodes a json lines file, a missing file has no lines.
+func readLines[T any](path string) ([]T, error) {
+	f, err := os.Open(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read crawl state: %w", err)
+	}
+	defer func() {
+		_ = f.Close()
+	}()
+	lines := []T{}
+	scanner := bufio.NewScanner(f)
+	scanner.Buffer(make([]byte, 0, 64*1024), 256*1024*1024)
+	for scanner.Scan() {
+		var v T
+		if err := json.Unmarshal(scanner.Bytes(), &v); err != nil {
+			continue
+		}
+		lines = append(lines, v)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read crawl state: %w", err)
+	}
+	return lines, nil
+}
+
+// restore loads a previous crawl from StateDir into the crawler and queue.
+func (c *Crawler) restore(q *queue) error {
+	pages, pending, err := loadState(c.StateDir)
+	if err != nil {
+		return err
+	}
+	c.mutex.Lock()
+	for _, p := range pages {
+		c.pagesContent[p.URL] = p.Content
+		if c.RecordGraph && p.Edges != nil {
+			if c.edges == nil {
+				c.edges = make(map[string][]Edge)
+			}
+			c.edges[p.URL] = p.Edges
+		}
+		if p.Result != nil {
+			result := *p.Result
+			c.pageResults[p.URL] = &result
+			c.collectedItems = append(c.collectedItems, result.Items...)
+		}
+	}
+	c.mutex.Unlock()
+	q.mutex.Lock()
+	defer q.mutex.Unlock()
+	for _, p := range pages {
+		q.seen[p.URL] = true
+	}
+	q.claimed = len(pages)
+	for _, e := range pending {
+		if q.seen[e.URL] {
+			continue
+		}
+		q.seen[e.URL] = true
+		q.frontier.Push(e)
+	}
+	if !c.Silent {
+		fmt.Printf("resuming crawl with %d pages done and %d queued\n", len(pages), len(pending))
+	}
+	return nil
+}
+
+// checkpoint writes a finished page to the state directory.
+func (c *Crawler) checkpoint(entry Entry) {
+	if c.state == nil {
+		return
+	}
+	record := Page{URL: entry.URL, Depth: entry.Depth}
+	c.mutex.Lock()
+	record.Content = c.pagesContent[entry.URL]
+	record.Edges = c.edges[entry.URL]
+	if r, ok := c.pageResults[entry.URL]; ok {
+		result := *r
+		record.Result = &result
+	}
+	c.mutex.Unlock()
+	c.state.finished(record)
+}
@@ -0,0 +1,105 @@
+package crawler
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync/atomic"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestResumeCrawl(t *testing.T) {
+	var fetches atomic.Int32
+	site := treeSite()
+	defer site.Close()
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fetches.Add(1)
+		site.Config.Handler.ServeHTTP(w, r)
+	}))
+	defer server.Close()
+	dir := t.TempDir()
+
+	first := NewCrawler()
+	first.Silent = true
+	first.MaxDepth = 3
+	first.MaxLinks = 4
+	first.StateDir = dir
+	results, err := first.Crawl(server.URL)
+	assert.NoError(t, err)
+	assert.Len(t, results, 4)
+
+	// simulate a crash in the middle of writing a page and a frontier entry
+	tear := func(name, line string) {
+		f, err := os.OpenFile(filepath.Join(dir, name), os.O_APPEND|os.O_WRONLY, 0o644)
+		assert.NoError(t, err)
+		_, _ = f.WriteString(line)
+		_ = f.Close()
+	}
+	tear(pagesFile, `{"url":"`+server.URL+`/1/1","dep`)
+	tear(frontierFile, `{"url":"`+server.URL+`/9","de`)
+
+	// the torn lines are cut off before appending, so the records written after them
+	// survive the next restart
+	second := NewCrawler()
+	second.Silent = true
+	second.MaxDepth = 3
+	second.MaxLinks = 8
+	second.StateDir = dir
+	second.Resume = true
+	results, err = second.Crawl(server.URL)
+	assert.NoError(t, err)
+	assert.Len(t, results, 8)
+
+	third := NewCrawler()
+	third.Silent = true
+	third.MaxDepth = 3
+	third.StateDir = dir
+	third.Resume = true
+	results, err = third.Crawl(server.URL)
+	assert.NoError(t, err)
+	assert.Len(t, results, 13)
+	assert.Equal(t, int32(13), fetches.Load(), "pages finished before a restart are not fetched again")
+	assert.Len(t, third.Results(), 13)
+}
+
+func TestTrimPartialLine(t *testing.T) {
+	tests := map[string]string{
+		"":              "",
+		"{}\n":          "{}\n",
+		"{}\n{\"url\":": "{}\n",
+		"{\"url\"":      "",
+		strings.Repeat("x", 5000) + "\n" + strings.Repeat("y", 5000): strings.Repeat("x", 5000) + "\n",
+	}
+	for content, want := range tests {
+		path := filepath.Join(t.TempDir(), "state.jsonl")
+		assert.NoError(t, os.WriteFile(path, []byte(content), 0o644))
+		f, err := openStateFile(path, true)
+		assert.NoError(t, err)
+		assert.NoError(t, f.Close())
+		data, err := os.ReadFile(path)
+		assert.NoError(t, err)
+		assert.Equal(t, want, string(data))
+	}
+}
+
+func TestStopCrawl(t *testing.T) {
+	c := NewCrawler()
+	c.Silent = true
+	c.MaxDepth = 5
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		c.Stop()
+		_, _ = w.Write([]byte(`<a href="/next">next</a>`))
+	}))
+	defer server.Close()
+	c.StateDir = t.TempDir()
+	results, err := c.Crawl(server.URL)
+	assert.NoError(t, err)
+	assert.Len(t, results, 1)
+	_, pending, err := loadState(c.StateDir)
+	assert.NoError(t, err)
+	assert.Equal(t, []Entry{{URL: server.URL + "/next", Depth: 2}}, pending)
+}